@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/launcher"
+)
+
+// buildFlags holds the flags shared by all commands that build the server.
+type buildFlags struct {
+	out       *string
+	recompile *bool
+	offline   *bool
+}
+
+// addBuildFlags adds the flags shared by all commands that build the server to the flag set.
+func addBuildFlags(flags *flag.FlagSet) buildFlags {
+	return buildFlags{
+		out: flags.String("out", "",
+			"Specifies an output file name for the server binary.",
+		),
+		recompile: flags.Bool("recompile", false,
+			"If set to true, the server will always be recompiled, and versions such as 'latest' are resolved again.",
+		),
+		offline: flags.Bool("offline", false,
+			"If set to true, no network access is used. The server is built from the versions pinned in saddle.lock, "+
+				"using only modules that are already downloaded.",
+		),
+	}
+}
+
+// options returns the launcher options for the flags, loading the configuration.
+func (bf buildFlags) options(logger *zerolog.Logger) launcher.Options {
+	return bf.optionsFor(logger, loadConfig(logger))
+}
+
+// optionsFor returns the launcher options for the flags and the configuration passed.
+func (bf buildFlags) optionsFor(logger *zerolog.Logger, cfg *config.Config) launcher.Options {
+	if *bf.out != "" {
+		cfg.Bundler.Path = *bf.out
+	}
+	return launcher.Options{
+		Config:    cfg,
+		Offline:   *bf.offline,
+		Recompile: *bf.recompile,
+		Logger:    logger,
+		Output:    commandOutput,
+	}
+}
+
+// buildCommand builds the server if needed, without running it.
+func buildCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	bf := addBuildFlags(flags)
+	flagFromVendor := flags.String("from-vendor", "",
+		"Builds the server from a directory created by 'saddle vendor' instead, without using the network.",
+	)
+	_ = flags.Parse(args)
+
+	ctx := context.Background()
+	if *flagFromVendor != "" {
+		logger.Info().Msgf("Building server from '%s'...", *flagFromVendor)
+		if _, err := launcher.BuildVendor(ctx, bf.options(logger), *flagFromVendor); err != nil {
+			logger.Fatal().Msgf("Could not build server: %v", err)
+		}
+		return
+	}
+	plan := resolvePlan(ctx, logger, bf)
+	res, err := launcher.Build(ctx, plan)
+	// The plan is closed before exiting, as logging a fatal message exits without running deferred calls.
+	_ = plan.Close()
+	if err != nil {
+		logger.Fatal().Msgf("Could not build server: %v", err)
+	}
+	if !res.Installed {
+		logger.Info().Msgf("The server is already up-to-date.")
+	}
+}
+
+// resolvePlan resolves the server that should be built for the configuration and flags. If the server could not be
+// resolved, the launcher exits. Resolve already removes any temporary files of the plan in that case.
+func resolvePlan(ctx context.Context, logger *zerolog.Logger, bf buildFlags) launcher.Plan {
+	opts := bf.options(logger)
+	if opts.Offline {
+		logger.Info().Msgf("Running in offline mode, only using versions pinned in saddle.lock.")
+	}
+	logger.Info().Msgf("Checking for updates...")
+	bars := newProgressBars(logger)
+	hooked := logger.Hook(bars)
+	opts.Logger, opts.Progress = &hooked, bars.Plugin
+	plan, err := launcher.Resolve(ctx, opts)
+	bars.Finish()
+	var unavailable launcher.UnavailableError
+	if errors.As(err, &unavailable) {
+		logger.Error().Msgf("The following are not available in offline mode:")
+		for _, u := range unavailable.Unavailable {
+			logger.Error().Msgf("  %s", u)
+		}
+		logger.Fatal().Msgf("Run the launcher without '-offline' first to download them.")
+	} else if err != nil {
+		logger.Fatal().Msgf("Could not resolve server: %v", err)
+	}
+	return plan
+}
+
+// rebuild resolves and builds the server again using the current saddle.toml. Unlike resolvePlan, problems are logged
+// without exiting the launcher, so that the previous server can be started again. It returns false if the server could
+// not be rebuilt.
+func rebuild(ctx context.Context, logger *zerolog.Logger, bf buildFlags) (launcher.Plan, launcher.Result, bool) {
+	cfg, err := config.Load(configPath)
+	var cfgErr *config.Error
+	if errors.As(err, &cfgErr) {
+		for _, msg := range cfgErr.Messages() {
+			logger.Error().Msgf("%s", msg)
+		}
+		logger.Error().Msgf("Not rebuilding the server, saddle.toml contains %d problem(s).", len(cfgErr.Problems))
+		return launcher.Plan{}, launcher.Result{}, false
+	} else if err != nil {
+		logger.Error().Msgf("Not rebuilding the server, unable to read saddle.toml: %v", err)
+		return launcher.Plan{}, launcher.Result{}, false
+	}
+
+	logger.Info().Msgf("Checking for updates...")
+	plan, err := launcher.Resolve(ctx, bf.optionsFor(logger, cfg))
+	if err != nil {
+		logger.Error().Msgf("Could not resolve server: %v", err)
+		return launcher.Plan{}, launcher.Result{}, false
+	}
+	defer plan.Close()
+	res, err := launcher.Build(ctx, plan)
+	if err != nil {
+		logger.Error().Msgf("Could not build server: %v", err)
+		return launcher.Plan{}, launcher.Result{}, false
+	}
+	if !res.Installed {
+		logger.Info().Msgf("The server is already up-to-date.")
+	}
+	return plan, res, true
+}
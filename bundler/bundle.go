@@ -1,6 +1,7 @@
 package bundler
 
 import (
+	"bytes"
 	_ "embed"
 	"fmt"
 	"os"
@@ -11,26 +12,34 @@ import (
 // Bundle bundles the provided bundler.Settings into a runnable application. It is not guaranteed to be compilable
 // without errors.
 func Bundle(set Settings) error {
-	mainFile, err := os.Create(path.Join(set.Path, "main.go"))
+	mainData, modData, err := Render(set)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path.Join(set.Path, "main.go"), mainData, 0644)
 	if err != nil {
 		return fmt.Errorf("error creating main.go: %w", err)
 	}
-	defer mainFile.Close()
-	modFile, err := os.Create(path.Join(set.Path, "go.mod"))
+	err = os.WriteFile(path.Join(set.Path, "go.mod"), modData, 0644)
 	if err != nil {
 		return fmt.Errorf("error creating go.mod: %w", err)
 	}
-	defer modFile.Close()
+	return nil
+}
 
-	err = mainTemplate.Execute(mainFile, set)
+// Render generates the contents of the main.go and go.mod files for the provided bundler.Settings without writing
+// them to disk. The Path of the settings is not used.
+func Render(set Settings) (mainData, modData []byte, err error) {
+	mainBuf, modBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	err = mainTemplate.Execute(mainBuf, set)
 	if err != nil {
-		return fmt.Errorf("error writing main.go: %w", err)
+		return nil, nil, fmt.Errorf("error writing main.go: %w", err)
 	}
-	err = modTemplate.Execute(modFile, set)
+	err = modTemplate.Execute(modBuf, set)
 	if err != nil {
-		return fmt.Errorf("error writing go.mod: %w", err)
+		return nil, nil, fmt.Errorf("error writing go.mod: %w", err)
 	}
-	return nil
+	return mainBuf.Bytes(), modBuf.Bytes(), nil
 }
 
 var (
@@ -0,0 +1,56 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/rogpeppe/go-internal/dirhash"
+	"sort"
+)
+
+// Fingerprint computes a hash over every input that influences the server binary built from the provided settings.
+// This includes the generated main.go and go.mod files, the contents of every module that is replaced with a local
+// directory and any extra inputs, such as the toolchain version or the configuration. If any of these change, the
+// fingerprint changes as well, meaning the server has to be rebuilt.
+func Fingerprint(set Settings, inputs map[string]string) (string, error) {
+	mainData, modData, err := Render(set)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	writeEntry := func(key, value string) {
+		// Both the key and the value are length-prefixed so that no two different sets of entries can produce the same
+		// stream of bytes.
+		_, _ = fmt.Fprintf(h, "%d:%s%d:%s", len(key), key, len(value), value)
+	}
+	writeEntry("main.go", string(mainData))
+	writeEntry("go.mod", string(modData))
+
+	// Replaced modules are not covered by the go.mod file, as only their path is present in there. Their contents
+	// therefore have to be hashed separately.
+	modules := append([]Module(nil), set.Modules...)
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	for _, m := range modules {
+		if m.Replace == "" {
+			continue
+		}
+		hash, err := dirhash.HashDir(m.Replace, "", dirhash.Hash1)
+		if err != nil {
+			return "", fmt.Errorf("error hashing replaced module %s: %w", m.Name, err)
+		}
+		writeEntry("replace:"+m.Name, hash)
+	}
+
+	keys := make([]string, 0, len(inputs))
+	for k := range inputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeEntry("input:"+k, inputs[k])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
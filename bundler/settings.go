@@ -10,6 +10,9 @@ type Settings struct {
 	Imports []Import
 	// Run is a fragment of code that should be added to the main function.
 	Run string
+	// GoVersion is the version used in the go directive of the go.mod file, such as "1.19". It is the minimum version
+	// of Go required to build the program.
+	GoVersion string
 }
 
 // Module represents a go module that is to be added to the go.mod file.
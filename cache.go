@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+	"text/tabwriter"
+)
+
+// cacheCommand manages the build cache. It accepts the 'ls' and 'prune' subcommands.
+func cacheCommand(logger *zerolog.Logger, args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: saddle cache ls")
+		fmt.Fprintln(os.Stderr, "       saddle cache prune [-all] [-max-size MB]")
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	cfg := loadConfig(logger)
+	c, err := launcher.OpenCache(cfg)
+	if err != nil {
+		logger.Fatal().Msgf("Could not open build cache: %v", err)
+	} else if c == nil {
+		logger.Fatal().Msgf("The build cache is disabled in saddle.toml.")
+	}
+
+	switch args[0] {
+	case "ls":
+		entries, err := c.List()
+		if err != nil {
+			logger.Fatal().Msgf("Could not read build cache: %v", err)
+		}
+		var total int64
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "FINGERPRINT\tSIZE\tLAST USED\tPLUGINS")
+		for _, e := range entries {
+			total += e.Size
+			lock, _ := config.ParseLock(e.Lock)
+			_, _ = fmt.Fprintf(w, "%.12s\t%s\t%s\t%d\n",
+				e.Fingerprint, formatSize(e.Size), e.Used.Format("2006-01-02 15:04"), len(lock.Plugins),
+			)
+		}
+		_ = w.Flush()
+		fmt.Printf("%d binaries, %s in total, stored in %s\n", len(entries), formatSize(total), c.Dir())
+	case "prune":
+		flags := flag.NewFlagSet("cache prune", flag.ExitOnError)
+		flagAll := flags.Bool("all", false,
+			"If set to true, all binaries are removed from the build cache.",
+		)
+		flagMaxSize := flags.Int64("max-size", cfg.Cache.MaxSize,
+			"The size in megabytes that the build cache should be pruned to.",
+		)
+		_ = flags.Parse(args[1:])
+
+		limit := *flagMaxSize * 1024 * 1024
+		if *flagAll {
+			limit = 0
+		}
+		removed, err := c.Prune(limit)
+		if err != nil {
+			logger.Fatal().Msgf("Could not prune build cache: %v", err)
+		}
+		var freed int64
+		for _, e := range removed {
+			freed += e.Size
+		}
+		logger.Info().Msgf("Removed %d binaries from the build cache, freeing %s.", len(removed), formatSize(freed))
+	default:
+		usage()
+	}
+}
+
+// formatSize formats a size in bytes in a human-readable way.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for x := n / unit; x >= unit; x /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
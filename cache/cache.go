@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// binaryName is the name of the server binary within a cache entry.
+	binaryName = "server"
+	// lockName is the name of the lock snapshot within a cache entry.
+	lockName = "saddle.lock"
+)
+
+// Cache is a content-addressed store of previously built server binaries. Binaries are keyed by the fingerprint of
+// the build that produced them, so that switching back to an earlier configuration does not require the server to be
+// rebuilt.
+type Cache struct {
+	dir string
+	// limit is the maximum total size of all entries in bytes. A limit of zero or lower means there is no limit.
+	limit int64
+}
+
+// Entry describes a single server binary stored in the cache.
+type Entry struct {
+	// Fingerprint is the build fingerprint that the binary was built from.
+	Fingerprint string
+	// Size is the size of the binary in bytes.
+	Size int64
+	// Used is the last time the entry was stored or restored.
+	Used time.Time
+	// Lock is the raw lock file that was written when the binary was built. It may be empty.
+	Lock json.RawMessage
+}
+
+// New returns a cache that stores its entries in the provided directory. The directory is created once the first entry
+// is stored. If the total size of the cache exceeds the limit (in bytes), the least recently used entries are removed.
+func New(dir string, limit int64) *Cache {
+	return &Cache{dir: dir, limit: limit}
+}
+
+// DefaultDir returns the default location of the build cache, which is inside the user's cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "saddle", "builds"), nil
+}
+
+// Dir returns the directory the cache stores its entries in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get restores the binary with the provided fingerprint to dst. If no such binary is present in the cache, false is
+// returned.
+func (c *Cache) Get(fingerprint, dst string) (bool, error) {
+	entry := filepath.Join(c.dir, fingerprint)
+	if _, err := os.Stat(filepath.Join(entry, binaryName)); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := copyFile(filepath.Join(entry, binaryName), dst); err != nil {
+		return false, fmt.Errorf("error restoring cached binary: %w", err)
+	}
+	// The modification time of the entry directory is used to keep track of when it was last used.
+	now := time.Now()
+	_ = os.Chtimes(entry, now, now)
+	return true, nil
+}
+
+// Put stores the binary at src in the cache together with the lock file data it was built with. Afterwards, the cache
+// is pruned so that it stays within its size limit.
+func (c *Cache) Put(fingerprint, src string, lock []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	// The entry is first written to a temporary directory, so that an interrupted write never leaves a broken entry
+	// behind under the fingerprint.
+	temp, err := os.MkdirTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+	if err := copyFile(src, filepath.Join(temp, binaryName)); err != nil {
+		return fmt.Errorf("error storing binary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(temp, lockName), lock, 0644); err != nil {
+		return fmt.Errorf("error storing lock: %w", err)
+	}
+
+	entry := filepath.Join(c.dir, fingerprint)
+	if err := os.RemoveAll(entry); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, entry); err != nil {
+		return err
+	}
+	if c.limit > 0 {
+		_, err = c.Prune(c.limit)
+	}
+	return err
+}
+
+// List returns all entries in the cache, with the most recently used entry first.
+func (c *Cache) List() ([]Entry, error) {
+	dirs, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(dirs))
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name()[0] == '.' {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil, err
+		}
+		bin, err := os.Stat(filepath.Join(c.dir, d.Name(), binaryName))
+		if err != nil {
+			// Entries without a binary are incomplete and can not be used.
+			continue
+		}
+		lock, _ := os.ReadFile(filepath.Join(c.dir, d.Name(), lockName))
+		entries = append(entries, Entry{
+			Fingerprint: d.Name(),
+			Size:        bin.Size(),
+			Used:        info.ModTime(),
+			Lock:        lock,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Used.After(entries[j].Used)
+	})
+	return entries, nil
+}
+
+// Prune removes the least recently used entries until the total size of the cache is at most limit bytes. A limit of
+// zero removes all entries. The removed entries are returned.
+func (c *Cache) Prune(limit int64) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		total   int64
+		removed []Entry
+	)
+	for _, e := range entries {
+		total += e.Size
+	}
+	for i := len(entries) - 1; i >= 0 && total > limit; i-- {
+		if err := os.RemoveAll(filepath.Join(c.dir, entries[i].Fingerprint)); err != nil {
+			return removed, err
+		}
+		total -= entries[i].Size
+		removed = append(removed, entries[i])
+	}
+	return removed, nil
+}
+
+// copyFile copies the executable at src to dst. The file at dst is replaced by renaming a temporary file over it, so
+// that it is never left half-written.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(out.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
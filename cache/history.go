@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrNoHistory is returned by History.Restore if there is no previous build to restore.
+var ErrNoHistory = errors.New("no previous build available")
+
+// History keeps the server binaries that were replaced by newer builds, together with the lock file they were built
+// with. This allows a server to be rolled back to the last build that was known to work.
+type History struct {
+	dir string
+	// keep is the amount of previous builds that are kept.
+	keep int
+}
+
+// Build is a previous server binary stored in the history.
+type Build struct {
+	// ID uniquely identifies the build within the history. Builds with a higher ID were replaced more recently.
+	ID string
+	// Replaced is the time at which the binary was replaced by a newer build.
+	Replaced time.Time
+	// Lock is the raw lock file that was active while the binary was in use. It may be empty.
+	Lock []byte
+
+	path string
+}
+
+// NewHistory returns a history that stores previous builds in the provided directory, keeping at most keep builds.
+func NewHistory(dir string, keep int) *History {
+	return &History{dir: dir, keep: keep}
+}
+
+// Push stores a copy of the binary at path in the history together with the lock file it was built with. The oldest
+// builds are removed if more builds than the history should keep are present. If keep is zero or lower, nothing is
+// stored.
+func (h *History) Push(path string, lock []byte) error {
+	if h.keep <= 0 {
+		return nil
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return err
+	}
+	temp, err := os.MkdirTemp(h.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+	if err := copyFile(path, filepath.Join(temp, binaryName)); err != nil {
+		return fmt.Errorf("error storing binary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(temp, lockName), lock, 0644); err != nil {
+		return fmt.Errorf("error storing lock: %w", err)
+	}
+	// The current time is used as ID, which keeps the directory names sortable.
+	id := fmt.Sprintf("%020d", time.Now().UnixNano())
+	if err := os.Rename(temp, filepath.Join(h.dir, id)); err != nil {
+		return err
+	}
+
+	builds, err := h.List()
+	if err != nil {
+		return err
+	}
+	for i := h.keep; i < len(builds); i++ {
+		if err := os.RemoveAll(builds[i].path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns all builds in the history, with the most recently replaced build first.
+func (h *History) List() ([]Build, error) {
+	dirs, err := os.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	builds := make([]Build, 0, len(dirs))
+	for _, d := range dirs {
+		nanos, err := strconv.ParseInt(d.Name(), 10, 64)
+		if !d.IsDir() || err != nil {
+			continue
+		}
+		path := filepath.Join(h.dir, d.Name())
+		if _, err := os.Stat(filepath.Join(path, binaryName)); err != nil {
+			continue
+		}
+		lock, _ := os.ReadFile(filepath.Join(path, lockName))
+		builds = append(builds, Build{
+			ID:       d.Name(),
+			Replaced: time.Unix(0, nanos),
+			Lock:     lock,
+			path:     path,
+		})
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].ID > builds[j].ID
+	})
+	return builds, nil
+}
+
+// Restore replaces the binary at dst with the most recently replaced build and removes that build from the history.
+// The lock file of the restored build is written to lockPath. The binary is swapped in atomically.
+func (h *History) Restore(dst, lockPath string) (Build, error) {
+	builds, err := h.List()
+	if err != nil {
+		return Build{}, err
+	}
+	if len(builds) == 0 {
+		return Build{}, ErrNoHistory
+	}
+	b := builds[0]
+	if err := copyFile(filepath.Join(b.path, binaryName), dst); err != nil {
+		return Build{}, fmt.Errorf("error restoring binary: %w", err)
+	}
+	if len(b.Lock) != 0 {
+		if err := os.WriteFile(lockPath, b.Lock, 0644); err != nil {
+			return Build{}, fmt.Errorf("error restoring lock: %w", err)
+		}
+	} else if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return Build{}, fmt.Errorf("error removing lock: %w", err)
+	}
+	return b, os.RemoveAll(b.path)
+}
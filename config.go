@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/plugin"
+	"github.com/saddlemc/launcher/plugin/provider"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//go:generate go run . config schema -o saddle.schema.json
+
+// configPath is the path of the config file, relative to the working directory.
+const configPath = "saddle.toml"
+
+// configCommand inspects the config file. It accepts the 'validate', 'show', 'migrate' and 'schema' subcommands.
+func configCommand(logger *zerolog.Logger, args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: saddle config validate")
+		fmt.Fprintln(os.Stderr, "       saddle config show")
+		fmt.Fprintln(os.Stderr, "       saddle config migrate [-dry-run]")
+		fmt.Fprintln(os.Stderr, "       saddle config schema [-o file]")
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "validate":
+		validateConfig(logger)
+	case "show":
+		showConfig(logger)
+	case "migrate":
+		flags := flag.NewFlagSet("config migrate", flag.ExitOnError)
+		flagDryRun := flags.Bool("dry-run", false, "Shows the changes that would be made without writing them.")
+		_ = flags.Parse(args[1:])
+		migrateConfig(logger, *flagDryRun)
+	case "schema":
+		flags := flag.NewFlagSet("config schema", flag.ExitOnError)
+		flagOut := flags.String("o", "", "Writes the schema to this file instead of printing it.")
+		_ = flags.Parse(args[1:])
+
+		var schemas []map[string]any
+		for _, r := range provider.Providers(provider.Options{}) {
+			schemas = append(schemas, r.Schema())
+		}
+		data, err := config.Schema(schemas)
+		if err != nil {
+			logger.Fatal().Msgf("Could not generate schema: %v", err)
+		}
+		if *flagOut == "" {
+			fmt.Println(string(data))
+			return
+		}
+		if err = os.WriteFile(*flagOut, append(data, '\n'), 0644); err != nil {
+			logger.Fatal().Msgf("Could not write schema: %v", err)
+		}
+		logger.Info().Msgf("Wrote schema for saddle.toml to '%s'.", *flagOut)
+	default:
+		usage()
+	}
+}
+
+// readConfig reads the config file without creating it, reporting every problem in it if it is invalid.
+func readConfig(logger *zerolog.Logger) *config.Config {
+	cfg, err := config.Load(configPath)
+	var cfgErr *config.Error
+	if errors.As(err, &cfgErr) {
+		for _, msg := range cfgErr.Messages() {
+			logger.Error().Msgf("%s", msg)
+		}
+		logger.Fatal().Msgf("%s is invalid.", configPath)
+	} else if err != nil {
+		logger.Fatal().Msgf("Could not read %s: %v", configPath, err)
+	}
+	return cfg
+}
+
+// validateConfig checks the config file and all of its plugin entries, reporting every problem that is found. Nothing
+// is downloaded, and the config file is not created if it does not exist.
+func validateConfig(logger *zerolog.Logger) {
+	cfg := readConfig(logger)
+	problems := 0
+	providers := provider.Providers(provider.Options{})
+	for num, info := range cfg.Plugin {
+		if _, err := plugin.ParseEntry(info, providers); err != nil {
+			origin := cfg.PluginOrigin(num)
+			p := config.Problem{Line: origin.Line, Message: fmt.Sprintf("entry #%d: %v", num+1, err)}
+			logger.Error().Msgf("%s:%s", origin.Path, p)
+			problems++
+		}
+	}
+	if problems > 0 {
+		logger.Fatal().Msgf("%s is invalid.", configPath)
+	}
+	logger.Info().Msgf("%s is valid.", configPath)
+}
+
+// migrateConfig migrates the config file to the current version of the format, printing the changes made. If dryRun
+// is true, the file is not changed.
+func migrateConfig(logger *zerolog.Logger, dryRun bool) {
+	res, err := config.MigrateFile(configPath, dryRun)
+	if err != nil {
+		logger.Fatal().Msgf("Could not migrate %s: %v", configPath, err)
+	}
+	if len(res.Applied) == 0 {
+		logger.Info().Msgf("%s is already up-to-date (config-version %d).", configPath, config.Version)
+		return
+	}
+	for _, m := range res.Applied {
+		logger.Info().Msgf("Version %d to %d: %s.", m.From, m.From+1, m.Description)
+	}
+	fmt.Print(unifiedDiff(configPath, configPath, string(res.Original), string(res.Migrated)))
+	if dryRun {
+		logger.Info().Msgf("Dry run, %s was not changed.", configPath)
+		return
+	}
+	logger.Info().Msgf("Migrated %s, a backup of the previous version was written to '%s'.", configPath, res.Backup)
+}
+
+// showConfig prints the effective config, after expanding environment variables, including other files and applying
+// overrides from the environment. Every value is followed by a comment saying where it came from.
+func showConfig(logger *zerolog.Logger) {
+	cfg := readConfig(logger)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	// Keys outside any table must be written before the first table.
+	settings := cfg.Settings()
+	sort.SliceStable(settings, func(i, j int) bool {
+		return !strings.Contains(settings[i].Key, ".") && strings.Contains(settings[j].Key, ".")
+	})
+	var table string
+	for _, s := range settings {
+		key := s.Key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			if key[:i] != table {
+				table = key[:i]
+				_, _ = fmt.Fprintf(w, "\n[%s]\n", table)
+			}
+			key = key[i+1:]
+		}
+		_, _ = fmt.Fprintf(w, "%s = %s\t# %s\n", key, formatValue(s.Value), s.Origin)
+	}
+	for num, info := range cfg.Plugin {
+		_, _ = fmt.Fprintf(w, "\n[[plugin]]\t# %s\n", cfg.PluginOrigin(num))
+		keys := make([]string, 0, len(info))
+		for k := range info {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(w, "%s = %s\n", k, formatValue(info[k]))
+		}
+	}
+	_ = w.Flush()
+}
+
+// formatValue formats a value of the config as it would be written in a config file.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, 0, len(v))
+		for _, s := range v {
+			quoted = append(quoted, strconv.Quote(s))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
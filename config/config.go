@@ -1,7 +1,10 @@
 package config
 
 import (
+	"bytes"
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/rs/zerolog"
 	"os"
@@ -13,10 +16,20 @@ var defaultConfig []byte
 type PluginInfo = map[string]any
 
 type Config struct {
+	// Version is the version of the format of the config file. Older config files are migrated to the current version
+	// when they are loaded.
+	Version int `toml:"config-version"`
+
 	Bundler struct {
 		Debug bool   `toml:"debug-log"`
 		Path  string `toml:"server-path"`
-	}
+		// KeepBuilds is the amount of previous server binaries that are kept after they are replaced by a new build, so
+		// that the server can be rolled back to them.
+		KeepBuilds int `toml:"keep-builds"`
+		// AutoRollback is the amount of seconds after starting a newly built server during which a crash causes the
+		// server to be rolled back to the previous build automatically. A value of 0 disables automatic rollbacks.
+		AutoRollback int `toml:"auto-rollback"`
+	} `toml:"bundler"`
 
 	Server struct {
 		Api string `toml:"api"`
@@ -29,36 +42,236 @@ type Config struct {
 		DragonflyReplace string `toml:"replace-dragonfly"`
 	} `toml:"server"`
 
+	Go struct {
+		// Path is the path to the go executable used to build the server. If empty, the go executable is looked up on
+		// the PATH, unless Root is set.
+		Path string `toml:"path"`
+		// Root is the GOROOT of the Go installation used to build the server. If set and Path is empty, the go
+		// executable in the bin directory of this installation is used.
+		Root string `toml:"root"`
+		// Version is the Go version used in the go directive of the generated go.mod file. The installed toolchain must
+		// be at least this version.
+		Version string `toml:"version"`
+
+		// Proxy, Private, NoSumDB and Insecure set the GOPROXY, GOPRIVATE, GONOSUMDB and GOINSECURE environment
+		// variables for every go command that the launcher runs. If empty, the value from the environment is used.
+		Proxy    string `toml:"proxy"`
+		Private  string `toml:"private"`
+		NoSumDB  string `toml:"no-sum-db"`
+		Insecure string `toml:"insecure"`
+		// Netrc is the path to a netrc file with the credentials used to access private modules.
+		Netrc string `toml:"netrc"`
+		// NetrcEnv is the name of an environment variable that contains netrc data with the credentials used to access
+		// private modules. This allows credentials to be used without storing them in a file.
+		NetrcEnv string `toml:"netrc-env"`
+	} `toml:"go"`
+
+	Cache struct {
+		// Enabled specifies if built server binaries should be stored in the build cache, so that switching back to a
+		// previous configuration does not require the server to be rebuilt.
+		Enabled bool `toml:"enabled"`
+		// Path is the directory that the build cache is stored in. If empty, a directory in the user's cache directory
+		// is used.
+		Path string `toml:"path"`
+		// MaxSize is the maximum size of the build cache in megabytes. If the cache grows larger than this, the least
+		// recently used binaries are removed. A value of 0 means that there is no limit.
+		MaxSize int64 `toml:"max-size"`
+	} `toml:"cache"`
+
+	Log struct {
+		// Format is the format that the launcher logs messages in, either "console" or "json".
+		Format string `toml:"format"`
+		// Level is the minimum level of the messages that are logged, such as "info" or "debug". If empty, debug
+		// messages are only logged if Bundler.Debug is set.
+		Level string `toml:"level"`
+		// File is the path of a file that all messages are also written to, in the JSON format. If empty, messages are
+		// only written to the terminal.
+		File string `toml:"file"`
+		// MaxSize is the size in megabytes after which the log file is rotated.
+		MaxSize int64 `toml:"max-size"`
+		// MaxFiles is the amount of rotated log files that are kept.
+		MaxFiles int `toml:"max-files"`
+	} `toml:"log"`
+
+	Output struct {
+		// Dir is the directory that the output of the server is logged to. If empty, the output is not logged.
+		Dir string `toml:"dir"`
+		// MaxSize is the size in megabytes after which the log file is rotated. A value of 0 disables this.
+		MaxSize int64 `toml:"max-size"`
+		// RotateInterval is the amount of hours after which the log file is rotated. A value of 0 disables this.
+		RotateInterval int `toml:"rotate-interval"`
+		// MaxFiles is the amount of rotated log files that are kept. A value of 0 keeps all of them.
+		MaxFiles int `toml:"max-files"`
+		// MaxAge is the amount of days after which rotated log files are removed. A value of 0 disables this.
+		MaxAge int `toml:"max-age"`
+		// Compress specifies if rotated log files should be compressed.
+		Compress bool `toml:"compress"`
+	} `toml:"output"`
+
+	Control struct {
+		// Enabled specifies if the launcher listens on a control socket while the server runs, so that other tools can
+		// get its status and restart, rebuild or stop the server.
+		Enabled bool `toml:"enabled"`
+		// Socket is the path of the unix socket that the launcher listens on.
+		Socket string `toml:"socket"`
+	} `toml:"control"`
+
+	Daemon struct {
+		// PidFile is the file that the process ID of a launcher running in the background is written to.
+		PidFile string `toml:"pid-file"`
+		// LogFile is the file that the output of a launcher running in the background is written to.
+		LogFile string `toml:"log-file"`
+	} `toml:"daemon"`
+
 	Plugin []PluginInfo `toml:"plugin"`
+
+	// Include holds glob patterns of files to read additional plugin entries from.
+	Include []string `toml:"include"`
+
+	// origins holds the origin of every setting that was not left at its default value, by its full key.
+	origins map[string]Origin
+	// pluginOrigins holds the origin of each plugin entry.
+	pluginOrigins []Origin
+}
+
+// defaults sets the default values for all settings that may be missing from older config files.
+func (c *Config) defaults() {
+	c.Bundler.Path = "./server"
+	c.Bundler.KeepBuilds = 3
+	c.Server.Api = "latest"
+	c.Server.Dragonfly = "latest"
+	c.Go.Version = "1.19"
+	c.Cache.Enabled = true
+	c.Cache.MaxSize = 2048
+	c.Log.Format = "console"
+	c.Log.MaxSize = 10
+	c.Log.MaxFiles = 5
+	c.Output.Dir = "logs"
+	c.Output.MaxSize = 50
+	c.Output.RotateInterval = 24
+	c.Output.MaxFiles = 20
+	c.Output.MaxAge = 30
+	c.Output.Compress = true
+	c.Control.Socket = ".saddle/control.sock"
+	c.Daemon.PidFile = ".saddle/saddle.pid"
+	c.Daemon.LogFile = "logs/saddle.log"
 }
 
 // GetOrMakeConfig tries to load the config file, and if it does not exist the default config file will be created and
 // loaded.
 func GetOrMakeConfig(log *zerolog.Logger, path string) *Config {
-	cfg := &Config{}
-	cfgData, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		log.Info().Msgf("Config file does not exist, creating default config...")
-		// Create the now config.toml file.
-		file, err := os.Create(path)
-		if err != nil {
-			log.Fatal().Msgf("Error trying to create saddle.toml file: %v", err)
+	}
+	cfg, res, err := loadOrCreate(path)
+	if len(res.Applied) > 0 {
+		for _, m := range res.Applied {
+			log.Info().Msgf("Migrated saddle.toml from version %d to %d: %s.", m.From, m.From+1, m.Description)
+		}
+		log.Info().Msgf("A backup of the previous saddle.toml was written to '%s'.", res.Backup)
+	}
+	var cfgErr *Error
+	if errors.As(err, &cfgErr) {
+		for _, msg := range cfgErr.Messages() {
+			log.Error().Msgf("%s", msg)
 		}
-		defer file.Close()
-		_, err = file.Write(defaultConfig)
+		log.Fatal().Msgf("Error trying to load saddle.toml file, it contains %d problem(s).", len(cfgErr.Problems))
+	} else if err != nil {
+		log.Fatal().Msgf("Error trying to load saddle.toml file: %v", err)
+	}
+	return cfg
+}
+
+// LoadOrCreate loads the config file at the path. If it does not exist, the default config file is created first. If it
+// uses an older version of the format, it is migrated in place, keeping a backup of the original file.
+func LoadOrCreate(path string) (*Config, error) {
+	cfg, _, err := loadOrCreate(path)
+	return cfg, err
+}
+
+// loadOrCreate loads the config file like LoadOrCreate, and also returns the result of migrating it. The result is
+// returned even if the migrated file could not be loaded.
+func loadOrCreate(path string) (*Config, MigrationResult, error) {
+	var res MigrationResult
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Create the now config.toml file.
+		err = os.WriteFile(path, defaultConfig, 0644)
 		if err != nil {
-			log.Fatal().Msgf("Error trying to create saddle.toml file: %v", err)
+			return nil, res, fmt.Errorf("error creating config file: %w", err)
 		}
+	} else if res, err = MigrateFile(path, false); err != nil {
+		return nil, res, fmt.Errorf("error migrating config file: %w", err)
+	}
+	cfg, err := Load(path)
+	return cfg, res, err
+}
 
-		// Make sure the new data is parsed.
-		cfgData = defaultConfig
-	} else if err != nil {
-		log.Fatal().Msgf("Error trying to open saddle.toml file: %v", err)
+// Load loads the config file at the path. Unlike LoadOrCreate, an error is returned if the file does not exist. If the
+// file is invalid, the error is an *Error.
+func Load(path string) (*Config, error) {
+	cfgData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %w", err)
 	}
+	return Parse(path, cfgData)
+}
 
-	err = toml.Unmarshal(cfgData, cfg)
+// Parse parses the data of a config file. If the config file uses an older version of the format, it is migrated first.
+// Keys that are not part of the config are not allowed, so that typos are noticed. If the data is invalid, the error is
+// an *Error describing every problem found, with its position in the file.
+//
+// After decoding, environment variables referenced as ${NAME} or ${NAME:-default} in string values are expanded, every
+// setting may be overridden by its SADDLE_* environment variable, and the plugins of the files listed in 'include' are
+// added. Included files are relative to the directory of the path.
+func Parse(path string, data []byte) (*Config, error) {
+	// Config files of an older version are migrated before they are decoded, so that they can always be read. Only
+	// LoadOrCreate writes the migrated file.
+	original := data
+	data, applied, err := Migrate(data)
 	if err != nil {
-		log.Fatal().Msgf("Error trying to parse saddle.toml file: %v", err)
+		return nil, &Error{Path: path, Problems: []Problem{{Message: err.Error()}}}
 	}
-	return cfg
+	// Positions are found in the migrated data, but must be reported in the file as the user wrote it.
+	lm := identityLines
+	if len(applied) > 0 {
+		lm = lineMap(original, data)
+	}
+	cfg := &Config{origins: map[string]Origin{}}
+	cfg.defaults()
+	err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(cfg)
+	if err != nil {
+		e := newError(path, err)
+		for i := range e.Problems {
+			e.Problems[i].Line = lm(e.Problems[i].Line)
+		}
+		return nil, e
+	}
+	lines := keyLines(data)
+	for key, line := range lines {
+		lines[key] = lm(line)
+	}
+	for _, f := range fields(cfg) {
+		if line, ok := lines[f.key]; ok {
+			cfg.origins[f.key] = Origin{Path: path, Line: line}
+		}
+	}
+	for _, line := range pluginLines(data) {
+		cfg.pluginOrigins = append(cfg.pluginOrigins, Origin{Path: path, Line: lm(line)})
+	}
+
+	if err = cfg.expand(path, lines); err != nil {
+		return nil, err
+	}
+	// Overrides are applied before files are included, so that SADDLE_INCLUDE is used to include them.
+	if err = cfg.override(); err != nil {
+		return nil, err
+	}
+	includeLine := lines["include"]
+	if cfg.origins["include"].Env != "" {
+		includeLine = 0
+	}
+	if err = cfg.include(path, includeLine); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
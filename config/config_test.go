@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseUnversionedUnknownKey(t *testing.T) {
+	data := []byte("[bundler]\nserver-pth = \"./server\"\n")
+	_, err := Parse("saddle.toml", data)
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if len(cfgErr.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got %v", cfgErr.Problems)
+	}
+	if p := cfgErr.Problems[0]; p.Line != 2 || p.Column != 1 {
+		t.Errorf("expected problem at 2:1, got %d:%d (%s)", p.Line, p.Column, p.Message)
+	}
+}
+
+func TestParseUnversionedOrigins(t *testing.T) {
+	data := []byte("# Comment.\n[bundler]\nkeep-builds = 5\n\n[[plugin]]\nlocal = \"./plugin\"\n")
+	cfg, err := Parse("saddle.toml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := cfg.origins["bundler.keep-builds"]; o.Line != 3 {
+		t.Errorf("expected bundler.keep-builds on line 3, got %d", o.Line)
+	}
+	if o := cfg.PluginOrigin(0); o.Line != 5 {
+		t.Errorf("expected plugin on line 5, got %d", o.Line)
+	}
+}
+
+func TestParseIncludeOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "extra.toml"), []byte("[[plugin]]\nlocal = \"./extra\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SADDLE_INCLUDE", "extra.toml")
+	cfg, err := Parse(filepath.Join(dir, "saddle.toml"), []byte("config-version = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Plugin) != 1 || cfg.Plugin[0]["local"] != "./extra" {
+		t.Errorf("expected the plugin of the included file, got %v", cfg.Plugin)
+	}
+}
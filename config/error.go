@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"strings"
+)
+
+// Problem is a single problem found in a config file.
+type Problem struct {
+	// Line and Column are the position of the problem in the file, starting at 1. They are 0 if the position is not
+	// known.
+	Line, Column int
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the problem prefixed with its position, such as "3:1: unknown key 'bundler.server-pth'".
+func (p Problem) String() string {
+	switch {
+	case p.Line == 0:
+		return p.Message
+	case p.Column == 0:
+		return fmt.Sprintf("%d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// Error is returned when a config file is invalid. It holds every problem that was found.
+type Error struct {
+	// Path is the path of the config file. It is empty if the problems were not found in a file, such as for invalid
+	// environment variables.
+	Path string
+	// Problems holds the problems found in the file, in the order they appear.
+	Problems []Problem
+}
+
+// Error returns all problems prefixed with the path of the file, one per line.
+func (e *Error) Error() string {
+	return strings.Join(e.Messages(), "\n")
+}
+
+// Messages returns a message for every problem, prefixed with the path of the file if it is known.
+func (e *Error) Messages() []string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		if e.Path == "" {
+			messages = append(messages, p.String())
+			continue
+		}
+		if p.Line == 0 {
+			messages = append(messages, e.Path+": "+p.String())
+			continue
+		}
+		messages = append(messages, e.Path+":"+p.String())
+	}
+	return messages
+}
+
+// newError converts an error returned while decoding a config file to an *Error.
+func newError(path string, err error) *Error {
+	var (
+		strict *toml.StrictMissingError
+		decode *toml.DecodeError
+	)
+	e := &Error{Path: path}
+	switch {
+	case errors.As(err, &strict):
+		for _, d := range strict.Errors {
+			line, column := d.Position()
+			e.Problems = append(e.Problems, Problem{
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("unknown key '%s'", strings.Join(d.Key(), ".")),
+			})
+		}
+	case errors.As(err, &decode):
+		line, column := decode.Position()
+		e.Problems = append(e.Problems, Problem{
+			Line:    line,
+			Column:  column,
+			Message: strings.TrimPrefix(decode.Error(), "toml: "),
+		})
+	default:
+		e.Problems = append(e.Problems, Problem{Message: strings.TrimPrefix(err.Error(), "toml: ")})
+	}
+	return e
+}
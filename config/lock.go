@@ -2,11 +2,17 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rs/zerolog"
 	"os"
+	"strings"
 )
 
-const LockVersion = 1
+const LockVersion = 2
+
+// ErrInvalidLock is returned when a lockfile could not be parsed.
+var ErrInvalidLock = errors.New("invalid lockfile")
 
 // LockFile contains information about the currently existing server binaries. It is used to determine whether it is
 // up-to-date with the latest configuration.
@@ -19,43 +25,141 @@ type LockFile struct {
 	Dragonfly string
 	// Plugins is a map which contains all the plugin checksums. The keys are the plugin module names.
 	Plugins map[string]string
+	// Fingerprint is a hash over every input that went into building the server binary. If the fingerprint of the
+	// current configuration differs from this one, the server needs to be rebuilt.
+	Fingerprint string
 }
 
-// GetLock returns the current lockfile. If it does not exist, or if the lockfile is of a previous version, an empty
-// lockfile and false will be returned.
+// GetLock returns the current lockfile. If it does not exist, or if it could not be parsed, an empty lockfile and false
+// will be returned.
 func GetLock(log *zerolog.Logger, path string) (LockFile, bool) {
-	lf := LockFile{
-		Version: LockVersion,
-		Plugins: map[string]string{},
+	lf, ok, err := LoadLock(path)
+	if errors.Is(err, ErrInvalidLock) {
+		log.Error().Msgf("Error trying to parse saddle.lock: %v. Using an empty saddle.lock file.", err)
+		return lf, false
+	} else if err != nil {
+		log.Fatal().Msgf("Error trying to open saddle.lock: %v", err)
 	}
+	return lf, ok
+}
 
+// LoadLock returns the lockfile at the path. If it does not exist or if it could not be parsed, an empty lockfile and
+// false will be returned. If the lockfile could not be parsed, an error wrapping ErrInvalidLock is returned as well.
+// Such an error can be safely ignored. Lockfiles of a previous version are migrated to the current version.
+func LoadLock(path string) (LockFile, bool, error) {
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
-		return lf, false
+		return emptyLock(), false, nil
 	} else if err != nil {
-		log.Fatal().Msgf("Error trying to open saddle.lock: %v", err)
+		return emptyLock(), false, err
 	}
-
-	err = json.Unmarshal(data, &lf)
+	lf, err := ParseLock(data)
 	if err != nil {
+		return emptyLock(), false, err
+	}
+	return lf, true, nil
+}
+
+// ParseLock parses the data of a lockfile, migrating it to the current version if it is of a previous version. If the
+// data could not be parsed, an error wrapping ErrInvalidLock is returned.
+func ParseLock(data []byte) (LockFile, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
 		// The saddle.lock data is only used to check if the server needs recompiling. In the event that the file could
-		// not be parsed (it may be outdated), an empty saddle.lock is returned instead.
-		log.Error().Msgf("Error trying to parse saddle.lock: %v. Using an empty saddle.lock file.", err)
-		return LockFile{
-			Version: LockVersion,
-			Plugins: map[string]string{},
-		}, false
+		// not be parsed (it may contain merge conflicts), an empty saddle.lock is used instead.
+		return emptyLock(), fmt.Errorf("%w: %v", ErrInvalidLock, err)
 	}
-	if lf.Version > LockVersion {
+	version, _ := raw["Version"].(float64)
+	if version > LockVersion {
 		// Do not override newer versions of the lockfile. We don't know if this may contain any important data in the
 		// future
-		log.Fatal().Msgf("Unknown lockfile version %d.", lf.Version)
-	} else if lf.Version < LockVersion {
-		// Older versions of the lockfile can be safely discarded. In this case we make
-		return LockFile{
-			Version: LockVersion,
-			Plugins: map[string]string{},
-		}, false
-	}
-	return lf, true
+		return emptyLock(), fmt.Errorf("unknown lockfile version %v", raw["Version"])
+	}
+	// Older versions are migrated one version at a time, so that the versions that plugins were pinned to are carried
+	// forward.
+	for v := int(version); v < LockVersion; v++ {
+		if migrate, ok := lockMigrations[v]; ok {
+			migrate(raw)
+		}
+		raw["Version"] = v + 1
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return emptyLock(), fmt.Errorf("%w: %v", ErrInvalidLock, err)
+	}
+	lf := emptyLock()
+	if err = json.Unmarshal(migrated, &lf); err != nil {
+		return emptyLock(), fmt.Errorf("%w: %v", ErrInvalidLock, err)
+	}
+	if lf.Plugins == nil {
+		lf.Plugins = map[string]string{}
+	}
+	return lf, nil
+}
+
+// lockMigrations holds the migrations of the decoded data of a lockfile from a version to the next, by the version
+// they migrate from. Versions that only added fields do not need a migration.
+var lockMigrations = map[int]func(raw map[string]any){
+	// Version 2 added the build fingerprint. Lockfiles of version 1 do not have one, so the server is rebuilt once,
+	// but the versions that were resolved are kept.
+	1: func(raw map[string]any) {
+		raw["Fingerprint"] = ""
+	},
+}
+
+// emptyLock returns an empty lockfile of the current version.
+func emptyLock() LockFile {
+	return LockFile{
+		Version: LockVersion,
+		Plugins: map[string]string{},
+	}
+}
+
+// EncodeLock encodes the lockfile. The output is indented and the plugins are sorted by their module name, so that
+// changes to the lockfile produce readable diffs.
+func EncodeLock(lf LockFile) ([]byte, error) {
+	// Maps are encoded with their keys sorted, so the output is always the same for the same lockfile.
+	data, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding lockfile: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteLock stores the lockfile at the path. The encoded lockfile is returned.
+func WriteLock(path string, lf LockFile) ([]byte, error) {
+	data, err := EncodeLock(lf)
+	if err != nil {
+		return nil, err
+	}
+	return data, os.WriteFile(path, data, 0644)
+}
+
+// SplitConflicts splits the data of a file containing git conflict markers into the two versions being merged. For every
+// conflict, ours holds the lines of the current branch and theirs holds the lines of the branch being merged in. If
+// the data has no conflicts, false is returned.
+func SplitConflicts(data []byte) (ours, theirs []byte, conflicted bool) {
+	// side is the side of the conflict that the current line belongs to: 0 outside of conflicts, 1 for ours, 2 for the
+	// common ancestor written by the diff3 conflict style, and 3 for theirs.
+	side := 0
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<<"):
+			side, conflicted = 1, true
+		case strings.HasPrefix(line, "|||||||") && side == 1:
+			side = 2
+		case strings.HasPrefix(line, "=======") && side != 0:
+			side = 3
+		case strings.HasPrefix(line, ">>>>>>>") && side != 0:
+			side = 0
+		case side == 0:
+			ours, theirs = append(ours, line...), append(theirs, line...)
+		case side == 1:
+			ours = append(ours, line...)
+		case side == 3:
+			theirs = append(theirs, line...)
+		}
+	}
+	return ours, theirs, conflicted
 }
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseLock(t *testing.T) {
+	plugins := map[string]string{"github.com/author/plugin": "v1.0.0"}
+	tests := []struct {
+		name    string
+		data    string
+		want    LockFile
+		invalid bool
+		err     bool
+	}{
+		{
+			name: "unversioned",
+			data: `{"Api":"v0.1.0","Dragonfly":"v0.9.0","Plugins":{"github.com/author/plugin":"v1.0.0"}}`,
+			want: LockFile{Version: LockVersion, Api: "v0.1.0", Dragonfly: "v0.9.0", Plugins: plugins},
+		},
+		{
+			name: "version 1",
+			data: `{"Version":1,"Api":"v0.1.0","Dragonfly":"v0.9.0","Plugins":{"github.com/author/plugin":"v1.0.0"}}`,
+			want: LockFile{Version: LockVersion, Api: "v0.1.0", Dragonfly: "v0.9.0", Plugins: plugins},
+		},
+		{
+			name: "version 2",
+			data: `{"Version":2,"Api":"v0.1.0","Dragonfly":"v0.9.0","Plugins":{"github.com/author/plugin":"v1.0.0"},"Fingerprint":"abc"}`,
+			want: LockFile{Version: 2, Api: "v0.1.0", Dragonfly: "v0.9.0", Plugins: plugins, Fingerprint: "abc"},
+		},
+		{
+			name: "without plugins",
+			data: `{"Version":2,"Plugins":null}`,
+			want: LockFile{Version: 2, Plugins: map[string]string{}},
+		},
+		{
+			name: "newer version",
+			data: `{"Version":3}`,
+			want: emptyLock(),
+			err:  true,
+		},
+		{
+			name:    "conflict markers",
+			data:    "<<<<<<< HEAD\n{\"Version\":2}\n=======\n{\"Version\":2}\n>>>>>>> branch\n",
+			want:    emptyLock(),
+			err:     true,
+			invalid: true,
+		},
+		{
+			name:    "wrong type",
+			data:    `{"Version":2,"Plugins":[]}`,
+			want:    emptyLock(),
+			err:     true,
+			invalid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lf, err := ParseLock([]byte(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("expected error: %v, got %v", test.err, err)
+			}
+			if errors.Is(err, ErrInvalidLock) != test.invalid {
+				t.Errorf("expected ErrInvalidLock: %v, got %v", test.invalid, err)
+			}
+			if !reflect.DeepEqual(lf, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, lf)
+			}
+		})
+	}
+}
+
+func TestEncodeLock(t *testing.T) {
+	lf := LockFile{
+		Version:   LockVersion,
+		Api:       "v0.1.0",
+		Dragonfly: "v0.9.0",
+		Plugins: map[string]string{
+			"github.com/b/plugin": "v2.0.0",
+			"github.com/a/plugin": "v1.0.0",
+		},
+		Fingerprint: "abc",
+	}
+	data, err := EncodeLock(lf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "Version": 2,
+  "Api": "v0.1.0",
+  "Dragonfly": "v0.9.0",
+  "Plugins": {
+    "github.com/a/plugin": "v1.0.0",
+    "github.com/b/plugin": "v2.0.0"
+  },
+  "Fingerprint": "abc"
+}
+`
+	if string(data) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, data)
+	}
+	decoded, err := ParseLock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, lf) {
+		t.Errorf("expected %+v after decoding, got %+v", lf, decoded)
+	}
+}
+
+func TestSplitConflicts(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		ours, theirs string
+		conflicted   bool
+	}{
+		{
+			name:   "no conflicts",
+			data:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\n",
+		},
+		{
+			name:       "single conflict",
+			data:       "a\n<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> branch\nd\n",
+			ours:       "a\nb\nd\n",
+			theirs:     "a\nc\nd\n",
+			conflicted: true,
+		},
+		{
+			name:       "multiple conflicts",
+			data:       "<<<<<<< HEAD\na\n=======\nb\n>>>>>>> branch\nc\n<<<<<<< HEAD\nd\n=======\ne\nf\n>>>>>>> branch\n",
+			ours:       "a\nc\nd\n",
+			theirs:     "b\nc\ne\nf\n",
+			conflicted: true,
+		},
+		{
+			name:       "diff3 style",
+			data:       "a\n<<<<<<< HEAD\nb\n||||||| base\nx\n=======\nc\n>>>>>>> branch\n",
+			ours:       "a\nb\n",
+			theirs:     "a\nc\n",
+			conflicted: true,
+		},
+		{
+			name:       "empty side",
+			data:       "a\n<<<<<<< HEAD\n=======\nc\n>>>>>>> branch\n",
+			ours:       "a\n",
+			theirs:     "a\nc\n",
+			conflicted: true,
+		},
+		{
+			name:       "no trailing newline",
+			data:       "<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> branch\nd",
+			ours:       "b\nd",
+			theirs:     "c\nd",
+			conflicted: true,
+		},
+		{
+			name:   "separator outside of conflict",
+			data:   "a\n=======\nb\n",
+			ours:   "a\n=======\nb\n",
+			theirs: "a\n=======\nb\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ours, theirs, conflicted := SplitConflicts([]byte(test.data))
+			if conflicted != test.conflicted {
+				t.Errorf("expected conflicted: %v, got %v", test.conflicted, conflicted)
+			}
+			if string(ours) != test.ours {
+				t.Errorf("expected ours %q, got %q", test.ours, ours)
+			}
+			if string(theirs) != test.theirs {
+				t.Errorf("expected theirs %q, got %q", test.theirs, theirs)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is the current version of the config format. Config files of an older version are migrated to this version
+// when they are loaded, and config files of a newer version are rejected.
+const Version = 1
+
+// Migration upgrades a config file from one version of the format to the next. Migrations work on the text of the file
+// rather than on the decoded config, so that comments and formatting are preserved.
+type Migration struct {
+	// From is the version that the migration upgrades from. It upgrades to From+1.
+	From int
+	// Description describes the changes made by the migration.
+	Description string
+	// Migrate returns the migrated data of a config file. It does not need to update the config-version key.
+	Migrate func(data []byte) ([]byte, error)
+}
+
+// migrations holds a migration from every version older than Version, in order.
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "add the config-version key",
+		// The first version of the format only introduced the config-version key, which is set after every
+		// migration.
+		Migrate: func(data []byte) ([]byte, error) { return data, nil },
+	},
+}
+
+// versionLine matches the line holding the config-version key.
+var versionLine = regexp.MustCompile(`(?m)^[ \t]*config-version[ \t]*=[ \t]*(\S+)[^\n]*$`)
+
+// FileVersion returns the version of the format of the data of a config file. Config files written before the format
+// was versioned have version 0.
+func FileVersion(data []byte) (int, error) {
+	// The version is read from the text, as the rest of the file may not be valid for the current version.
+	m := versionLine.FindSubmatch(headerOf(data))
+	if m == nil {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(string(m[1]))
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid config-version '%s'", m[1])
+	}
+	return v, nil
+}
+
+// Migrate migrates the data of a config file to the current version of the format. It returns the migrated data and
+// the migrations that were applied, which is empty if the file was already up-to-date.
+func Migrate(data []byte) ([]byte, []Migration, error) {
+	v, err := FileVersion(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v > Version {
+		return nil, nil, fmt.Errorf("config-version %d is newer than the latest version supported by this launcher (%d), "+
+			"update the launcher to use this config file", v, Version)
+	}
+	var applied []Migration
+	for _, m := range migrations[v:] {
+		if data, err = m.Migrate(data); err != nil {
+			return nil, nil, fmt.Errorf("error migrating config from version %d to %d: %w", m.From, m.From+1, err)
+		}
+		data = setVersion(data, m.From+1)
+		applied = append(applied, m)
+	}
+	return data, applied, nil
+}
+
+// MigrationResult is the result of migrating a config file.
+type MigrationResult struct {
+	// Original and Migrated are the data of the config file before and after migrating it.
+	Original, Migrated []byte
+	// Applied holds the migrations that were applied. It is empty if the file was already up-to-date.
+	Applied []Migration
+	// Backup is the path of the copy of the original file. It is empty if no backup was written.
+	Backup string
+}
+
+// MigrateFile migrates the config file at the path to the current version of the format, if it is outdated. Before the
+// file is overwritten, the original is copied to a backup file next to it, named after its version. If dryRun is true,
+// nothing is written.
+func MigrateFile(path string, dryRun bool) (MigrationResult, error) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return MigrationResult{}, fmt.Errorf("error opening config file: %w", err)
+	}
+	v, err := FileVersion(original)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	migrated, applied, err := Migrate(original)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	res := MigrationResult{Original: original, Migrated: migrated, Applied: applied}
+	if len(applied) == 0 || dryRun {
+		return res, nil
+	}
+	res.Backup = fmt.Sprintf("%s.v%d.bak", path, v)
+	if err = os.WriteFile(res.Backup, original, 0644); err != nil {
+		return MigrationResult{}, fmt.Errorf("error writing backup of config file: %w", err)
+	}
+	if err = os.WriteFile(path, migrated, 0644); err != nil {
+		return MigrationResult{}, fmt.Errorf("error writing migrated config file: %w", err)
+	}
+	return res, nil
+}
+
+// setVersion sets the config-version key in the data of a config file. If the key is not present yet, it is added
+// before the first key, after any comments at the top of the file.
+func setVersion(data []byte, v int) []byte {
+	line := []byte("config-version = " + strconv.Itoa(v))
+	header := headerOf(data)
+	if loc := versionLine.FindIndex(header); loc != nil {
+		return append(append(append([]byte{}, data[:loc[0]]...), line...), data[loc[1]:]...)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	i := 0
+	for i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(strings.TrimSpace(lines[i]), "#")) {
+		i++
+	}
+	// The comments directly above the first key describe that key, so the version is added above them.
+	for i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#") {
+		i--
+	}
+	out := strings.Join(lines[:i], "") + string(line) + "\n\n" + strings.Join(lines[i:], "")
+	return []byte(out)
+}
+
+// tableHeader matches the header of a table.
+var tableHeader = regexp.MustCompile(`(?m)^[ \t]*\[`)
+
+// headerOf returns the part of the data of a config file before the first table, which holds all top-level keys.
+func headerOf(data []byte) []byte {
+	if loc := tableHeader.FindIndex(data); loc != nil {
+		return data[:loc[0]]
+	}
+	return data
+}
+
+// identityLines maps every line to itself. It is used for data that was not migrated.
+func identityLines(line int) int {
+	return line
+}
+
+// lineMap returns a function that maps a line in the migrated data of a config file to the line in the original data
+// it came from, so that positions found after migrating can be reported in the file as it was written. Lines that
+// were changed by a migration are mapped to the line they replaced, and lines that were added to the next original
+// line. Lines start at 1, and 0 is returned as it is.
+func lineMap(original, migrated []byte) func(line int) int {
+	a, b := strings.Split(string(original), "\n"), strings.Split(string(migrated), "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	m := make([]int, len(b)+1)
+	i, j := 0, 0
+	for j < len(b) {
+		switch {
+		case i < len(a) && a[i] == b[j]:
+			m[j+1] = i + 1
+			i, j = i+1, j+1
+		case i < len(a) && lcs[i+1][j] > lcs[i][j+1]:
+			i++
+		default:
+			m[j+1] = i + 1
+			if m[j+1] > len(a) {
+				m[j+1] = len(a)
+			}
+			j++
+		}
+	}
+	return func(line int) int {
+		if line <= 0 || line >= len(m) {
+			return line
+		}
+		return m[line]
+	}
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	if len(migrations) != Version {
+		t.Fatalf("expected a migration from every version before %d, got %d migration(s)", Version, len(migrations))
+	}
+	for i, m := range migrations {
+		if m.From != i {
+			t.Errorf("expected migration #%d to migrate from version %d, got %d", i+1, i, m.From)
+		}
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want int
+		err  bool
+	}{
+		{name: "unversioned", data: "[bundler]\nserver-path = \"./server\"\n", want: 0},
+		{name: "empty", data: "", want: 0},
+		{name: "versioned", data: "config-version = 1\n", want: 1},
+		{name: "spacing and comment", data: "# Header.\n  config-version=2 # Comment.\n", want: 2},
+		{name: "inside table", data: "[server]\nconfig-version = 1\n", want: 0},
+		{name: "not a number", data: "config-version = \"1\"\n", err: true},
+		{name: "negative", data: "config-version = -1\n", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := FileVersion([]byte(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("expected error: %v, got %v", test.err, err)
+			}
+			if v != test.want {
+				t.Errorf("expected version %d, got %d", test.want, v)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		applied int
+		err     bool
+	}{
+		{
+			name:    "version 0",
+			data:    "[bundler]\nserver-path = \"./server\"\n",
+			want:    "config-version = 1\n\n[bundler]\nserver-path = \"./server\"\n",
+			applied: 1,
+		},
+		{
+			name: "up-to-date",
+			data: "config-version = 1\n\n[bundler]\nserver-path = \"./server\"\n",
+			want: "config-version = 1\n\n[bundler]\nserver-path = \"./server\"\n",
+		},
+		{
+			name: "newer version",
+			data: "config-version = 2\n",
+			err:  true,
+		},
+		{
+			name: "invalid version",
+			data: "config-version = one\n",
+			err:  true,
+		},
+		{
+			name: "negative version",
+			data: "config-version = -1\n",
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, applied, err := Migrate([]byte(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("expected error: %v, got %v", test.err, err)
+			}
+			if string(data) != test.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.want, data)
+			}
+			if len(applied) != test.applied {
+				t.Errorf("expected %d migration(s) to be applied, got %d", test.applied, len(applied))
+			}
+		})
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "before first table",
+			data: "[bundler]\nkeep-builds = 5\n",
+			want: "config-version = 1\n\n[bundler]\nkeep-builds = 5\n",
+		},
+		{
+			name: "after header comment",
+			data: "# Header.\n\ninclude = []\n",
+			want: "# Header.\n\nconfig-version = 1\n\ninclude = []\n",
+		},
+		{
+			name: "above comment of first key",
+			data: "# Header.\n\n# Files to include.\ninclude = []\n",
+			want: "# Header.\n\nconfig-version = 1\n\n# Files to include.\ninclude = []\n",
+		},
+		{
+			name: "replaces existing",
+			data: "config-version = 0 # Old.\ninclude = []\n",
+			want: "config-version = 1\ninclude = []\n",
+		},
+		{
+			name: "ignores tables",
+			data: "[server]\nconfig-version = 0\n",
+			want: "config-version = 1\n\n[server]\nconfig-version = 0\n",
+		},
+		{
+			name: "empty",
+			data: "",
+			want: "config-version = 1\n\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := setVersion([]byte(test.data), 1); string(got) != test.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLineMap(t *testing.T) {
+	tests := []struct {
+		name               string
+		original, migrated string
+		// want holds the original line of every line of the migrated data, starting at line 1.
+		want []int
+	}{
+		{
+			name:     "unchanged",
+			original: "a\nb\n",
+			migrated: "a\nb\n",
+			want:     []int{1, 2, 3},
+		},
+		{
+			name:     "added at top",
+			original: "[bundler]\nkeep-builds = 5\n",
+			migrated: "config-version = 1\n\n[bundler]\nkeep-builds = 5\n",
+			want:     []int{1, 1, 1, 2, 3},
+		},
+		{
+			name:     "added after comment",
+			original: "# Header.\n\ninclude = []\n",
+			migrated: "# Header.\n\nconfig-version = 1\n\ninclude = []\n",
+			want:     []int{1, 2, 3, 3, 3, 4},
+		},
+		{
+			name:     "changed",
+			original: "config-version = 0\ninclude = []\n",
+			migrated: "config-version = 1\ninclude = []\n",
+			want:     []int{1, 2, 3},
+		},
+		{
+			name:     "added at end",
+			original: "a",
+			migrated: "a\nb",
+			want:     []int{1, 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lm := lineMap([]byte(test.original), []byte(test.migrated))
+			for i, want := range test.want {
+				if got := lm(i + 1); got != want {
+					t.Errorf("expected line %d to map to %d, got %d", i+1, want, got)
+				}
+			}
+			// Lines that are out of range are returned as they are.
+			for _, line := range []int{0, len(test.want) + 1} {
+				if got := lm(line); got != line {
+					t.Errorf("expected line %d to be returned as it is, got %d", line, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	const original = "[bundler]\nkeep-builds = 5\n"
+	tests := []struct {
+		name   string
+		data   string
+		dryRun bool
+		backup bool
+	}{
+		{name: "outdated", data: original, backup: true},
+		{name: "dry run", data: original, dryRun: true},
+		{name: "up-to-date", data: "config-version = 1\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "saddle.toml")
+			if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			res, err := MigrateFile(path, test.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.backup {
+				if res.Backup != "" || string(data) != test.data {
+					t.Errorf("expected the file to be left untouched, got backup '%s' and:\n%s", res.Backup, data)
+				}
+				return
+			}
+			if string(data) != string(res.Migrated) {
+				t.Errorf("expected the migrated data to be written, got:\n%s", data)
+			}
+			if want := path + ".v0.bak"; res.Backup != want {
+				t.Fatalf("expected backup at '%s', got '%s'", want, res.Backup)
+			}
+			backup, err := os.ReadFile(res.Backup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(backup) != test.data {
+				t.Errorf("expected the backup to hold the original data, got:\n%s", backup)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the identifier of the JSON schema for config files.
+const SchemaID = "https://github.com/saddlemc/launcher/saddle.schema.json"
+
+// Schema returns a JSON schema for config files, which editors may use to validate config files and to provide
+// autocompletion. As the keys of plugin entries depend on the providers used, the schema for each type of plugin entry
+// is passed. A plugin entry must match at least one of them.
+func Schema(plugins []map[string]any) ([]byte, error) {
+	descriptions := keyDescriptions(defaultConfig)
+	root := structSchema(reflect.TypeOf(Config{}), "", descriptions)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["$id"] = SchemaID
+	root["title"] = "saddle.toml"
+
+	items := make([]any, 0, len(plugins))
+	for _, p := range plugins {
+		items = append(items, p)
+	}
+	plugin := map[string]any{"type": "object"}
+	if len(items) > 0 {
+		plugin = map[string]any{"anyOf": items}
+	}
+	root["properties"].(map[string]any)["plugin"] = map[string]any{
+		"type":        "array",
+		"description": "The plugins that are installed on the server.",
+		"items":       plugin,
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// structSchema returns the schema for a struct decoded from a config file. The prefix is the key of the table that the
+// struct is decoded from, followed by a dot, and is used to look up the descriptions of keys.
+func structSchema(t reflect.Type, prefix string, descriptions map[string]string) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tomlName(f)
+		if name == "" {
+			continue
+		}
+		var s map[string]any
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			s = structSchema(f.Type, prefix+name+".", descriptions)
+		case reflect.Bool:
+			s = map[string]any{"type": "boolean"}
+		case reflect.Int, reflect.Int64:
+			s = map[string]any{"type": "integer"}
+		case reflect.String:
+			s = map[string]any{"type": "string"}
+		case reflect.Slice:
+			if f.Type.Elem().Kind() == reflect.String {
+				s = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+				break
+			}
+			// Other slices, such as the plugin list, are described by the caller.
+			s = map[string]any{}
+		default:
+			s = map[string]any{}
+		}
+		if d, ok := descriptions[prefix+name]; ok {
+			s["description"] = d
+		}
+		properties[name] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// keyDescriptions reads the descriptions of keys from the comments in a config file, by their full key, such as
+// "bundler.debug-log". A comment describes all keys that directly follow it.
+func keyDescriptions(data []byte) map[string]string {
+	descriptions := map[string]string{}
+	var table string
+	var comment []string
+	// described is true if the last comment was already used by a key, in which case another comment replaces it.
+	described := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			comment, described = nil, false
+		case strings.HasPrefix(line, "#"):
+			if described {
+				comment, described = nil, false
+			}
+			comment = append(comment, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+		case strings.HasPrefix(line, "["):
+			table = strings.Trim(line, "[] ")
+			comment, described = nil, false
+		default:
+			if len(comment) > 0 {
+				descriptions[joinKey(table, line)] = strings.Join(comment, " ")
+				described = true
+			}
+		}
+	}
+	return descriptions
+}
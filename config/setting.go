@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Origin describes where the value of a setting came from.
+type Origin struct {
+	// Path and Line are the file and line that the value was read from. If Path is empty, the value was not read from
+	// a file.
+	Path string
+	Line int
+	// Env is the environment variable that the value was read from, if any.
+	Env string
+	// Expanded holds the environment variables that were expanded in the value read from the file.
+	Expanded []string
+}
+
+// String describes the origin, such as "saddle.toml:3" or "environment variable SADDLE_BUNDLER_SERVER_PATH".
+func (o Origin) String() string {
+	var s string
+	switch {
+	case o.Env != "":
+		return "environment variable " + o.Env
+	case o.Path == "":
+		return "default"
+	case o.Line == 0:
+		s = o.Path
+	default:
+		s = fmt.Sprintf("%s:%d", o.Path, o.Line)
+	}
+	if len(o.Expanded) > 0 {
+		s += " with ${" + strings.Join(o.Expanded, "}, ${") + "}"
+	}
+	return s
+}
+
+// Setting is a single setting of the config and its value.
+type Setting struct {
+	// Key is the full key of the setting, such as "bundler.server-path".
+	Key string
+	// Value is the effective value of the setting.
+	Value any
+	// Origin is where the value came from.
+	Origin Origin
+}
+
+// Settings returns all settings of the config except for the plugins, in the order they are defined in, together with
+// the origin of their values.
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	for _, f := range fields(c) {
+		settings = append(settings, Setting{Key: f.key, Value: f.value.Interface(), Origin: c.origins[f.key]})
+	}
+	return settings
+}
+
+// PluginOrigin returns the origin of the plugin entry with the index passed. The line of the origin is the line that
+// the entry starts at.
+func (c *Config) PluginOrigin(index int) Origin {
+	if index < 0 || index >= len(c.pluginOrigins) {
+		return Origin{}
+	}
+	return c.pluginOrigins[index]
+}
+
+// field is a setting of the config that can be set through a field of the Config struct.
+type field struct {
+	// key is the full key of the setting, such as "bundler.server-path".
+	key   string
+	value reflect.Value
+}
+
+// env returns the name of the environment variable that overrides the field, such as "SADDLE_BUNDLER_SERVER_PATH".
+func (f field) env() string {
+	return "SADDLE_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(f.key))
+}
+
+// fields returns all fields of the config that hold a setting. The plugins are not included.
+func fields(c *Config) []field {
+	return structFields(reflect.ValueOf(c).Elem(), "")
+}
+
+// structFields returns the fields in a struct decoded from a config file. The prefix is the key of the table that the
+// struct is decoded from, followed by a dot.
+func structFields(v reflect.Value, prefix string) []field {
+	var list []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tomlName(f)
+		if name == "" || name == "plugin" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			list = append(list, structFields(v.Field(i), prefix+name+".")...)
+			continue
+		}
+		list = append(list, field{key: prefix + name, value: v.Field(i)})
+	}
+	return list
+}
+
+// tomlName returns the key of a struct field in a config file, or an empty string if the field is not decoded.
+func tomlName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("toml"), ",")[0]
+	if !f.IsExported() || name == "-" {
+		return ""
+	}
+	return name
+}
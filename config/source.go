@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// variable matches a reference to an environment variable in a string value, such as ${HOME} or ${DIR:-/srv}.
+var variable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?}`)
+
+// expand expands references to environment variables in all string values of the config, including those of the
+// plugin entries. The lines of the keys in the file are used to report problems.
+func (c *Config) expand(path string, lines map[string]int) error {
+	e := &Error{Path: path}
+	for _, f := range fields(c) {
+		if f.value.Kind() != reflect.String {
+			continue
+		}
+		s, names, err := expandString(f.value.String())
+		if err != nil {
+			e.Problems = append(e.Problems, Problem{Line: lines[f.key], Message: fmt.Sprintf("%s: %v", f.key, err)})
+			continue
+		}
+		f.value.SetString(s)
+		if len(names) > 0 {
+			o := c.origins[f.key]
+			o.Expanded = names
+			c.origins[f.key] = o
+		}
+	}
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return c.expandPlugins(path, 0)
+}
+
+// expandPlugins expands references to environment variables in the string values of the plugin entries, starting at
+// the index passed. These entries must have been read from the path.
+func (c *Config) expandPlugins(path string, from int) error {
+	e := &Error{Path: path}
+	for i := from; i < len(c.Plugin); i++ {
+		info := c.Plugin[i]
+		for _, k := range sortedKeys(info) {
+			v, ok := info[k].(string)
+			if !ok {
+				continue
+			}
+			s, _, err := expandString(v)
+			if err != nil {
+				e.Problems = append(e.Problems, Problem{
+					Line:    c.PluginOrigin(i).Line,
+					Message: fmt.Sprintf("plugin entry #%d: %s: %v", i+1, k, err),
+				})
+				continue
+			}
+			info[k] = s
+		}
+	}
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+// expandString expands all references to environment variables in s, returning the names of the variables expanded.
+// An error is returned if a variable without a default value is not set.
+func expandString(s string) (string, []string, error) {
+	var (
+		names []string
+		err   error
+	)
+	s = variable.ReplaceAllStringFunc(s, func(ref string) string {
+		m := variable.FindStringSubmatch(ref)
+		names = append(names, m[1])
+		if v, ok := os.LookupEnv(m[1]); ok {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		err = fmt.Errorf("environment variable '%s' is not set", m[1])
+		return ref
+	})
+	return s, names, err
+}
+
+// include adds the plugin entries of all files matching the patterns in Include to the config. The patterns are
+// relative to the directory of the path. Included files may only contain plugin entries.
+func (c *Config) include(path string, line int) error {
+	dir := filepath.Dir(path)
+	for _, pattern := range c.Include {
+		s, _, err := expandString(pattern)
+		if err != nil {
+			return &Error{Path: path, Problems: []Problem{{Line: line, Message: "include: " + err.Error()}}}
+		}
+		if !filepath.IsAbs(s) {
+			s = filepath.Join(dir, s)
+		}
+		matches, err := filepath.Glob(s)
+		if err != nil {
+			return &Error{Path: path, Problems: []Problem{{Line: line, Message: fmt.Sprintf("include: invalid pattern '%s'", pattern)}}}
+		}
+		// Glob returns the files in lexical order already, so that entries are always added in the same order.
+		for _, m := range matches {
+			data, err := os.ReadFile(m)
+			if err != nil {
+				return fmt.Errorf("error reading included file: %w", err)
+			}
+			var included struct {
+				Plugin []PluginInfo `toml:"plugin"`
+			}
+			if err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(&included); err != nil {
+				return newError(m, err)
+			}
+			n := len(c.Plugin)
+			c.Plugin = append(c.Plugin, included.Plugin...)
+			for _, l := range pluginLines(data) {
+				c.pluginOrigins = append(c.pluginOrigins, Origin{Path: m, Line: l})
+			}
+			if err = c.expandPlugins(m, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// override overrides every setting for which the SADDLE_* environment variable is set, such as
+// SADDLE_BUNDLER_SERVER_PATH for 'server-path' in the [bundler] table. Lists are separated by commas.
+func (c *Config) override() error {
+	e := &Error{}
+	for _, f := range fields(c) {
+		name := f.env()
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		var err error
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(v)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(v)
+			f.value.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(v, 10, 64)
+			f.value.SetInt(n)
+		case reflect.Slice:
+			var list []string
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			f.value.Set(reflect.ValueOf(list))
+		}
+		if err != nil {
+			e.Problems = append(e.Problems, Problem{Message: fmt.Sprintf("environment variable %s: invalid value '%s' for %s", name, v, f.key)})
+			continue
+		}
+		c.origins[f.key] = Origin{Env: name}
+	}
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+// keyLines returns the line of every key in the data of a config file, by its full key. Only keys that hold a single
+// value are included.
+func keyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	var table string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "["):
+			table = strings.Trim(line, "[] ")
+		case strings.Contains(line, "="):
+			lines[joinKey(table, line)] = i + 1
+		}
+	}
+	return lines
+}
+
+// joinKey returns the full key assigned on a line of a config file, such as "bundler.server-path" for the line
+// 'server-path = "./server"' in the [bundler] table.
+func joinKey(table, line string) string {
+	key := strings.Trim(strings.TrimSpace(strings.SplitN(line, "=", 2)[0]), `"`)
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+// pluginLines returns the line of each [[plugin]] header in the data of a config file.
+func pluginLines(data []byte) []int {
+	var lines []int
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.ReplaceAll(strings.TrimSpace(line), " ", "") == "[[plugin]]" {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// sortedKeys returns the keys of a plugin entry in alphabetical order.
+func sortedKeys(info PluginInfo) []string {
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/console"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// commandPrefix is the prefix of lines typed in the console that are commands for the launcher rather than for the
+// server. Lines starting with the prefix twice are sent to the server with a single prefix.
+const commandPrefix = ":"
+
+// serverConsole owns the terminal while the server runs. Lines typed are forwarded to the server, except for launcher
+// commands, which start with the command prefix.
+type serverConsole struct {
+	con    *console.Console
+	logger *zerolog.Logger
+	sess   *session
+
+	mu sync.Mutex
+	// stdin is the pipe that lines are written to for the current server. eof is true once the end of the input was
+	// reached.
+	stdin *os.File
+	eof   bool
+}
+
+// newServerConsole returns a console using the terminal. Lines are only read once start is called.
+func newServerConsole() *serverConsole {
+	return &serverConsole{con: console.New(os.Stdin, os.Stdout)}
+}
+
+// start starts reading lines from the console, handling commands for the session.
+func (sc *serverConsole) start(logger *zerolog.Logger, sess *session) {
+	sc.logger, sc.sess = logger, sess
+	go sc.read()
+}
+
+// read reads lines from the console until the end of the input.
+func (sc *serverConsole) read() {
+	for {
+		line, err := sc.con.ReadLine()
+		if err != nil {
+			// The end of the input is passed on to the server, like it would be if it read from the terminal.
+			sc.mu.Lock()
+			sc.eof = true
+			if sc.stdin != nil {
+				_ = sc.stdin.Close()
+				sc.stdin = nil
+			}
+			sc.mu.Unlock()
+			return
+		}
+		if strings.HasPrefix(line, commandPrefix+commandPrefix) {
+			line = strings.TrimPrefix(line, commandPrefix)
+		} else if strings.HasPrefix(line, commandPrefix) {
+			sc.command(strings.TrimPrefix(line, commandPrefix))
+			continue
+		}
+		sc.mu.Lock()
+		if sc.stdin != nil {
+			_, _ = io.WriteString(sc.stdin, line+"\n")
+		}
+		sc.mu.Unlock()
+	}
+}
+
+// input returns the input for a new server, which the lines typed are written to from now on. The server reads from a
+// pipe rather than from the terminal, which is owned by the console.
+func (sc *serverConsole) input() (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.stdin != nil {
+		_ = sc.stdin.Close()
+	}
+	sc.stdin = w
+	if sc.eof {
+		_ = w.Close()
+		sc.stdin = nil
+	}
+	return r, nil
+}
+
+// Run restores the terminal before a fatal message is logged, as the launcher exits right after. It allows the console
+// to be used as a hook for loggers.
+func (sc *serverConsole) Run(_ *zerolog.Event, level zerolog.Level, _ string) {
+	if level == zerolog.FatalLevel {
+		_ = sc.con.Close()
+	}
+}
+
+// Close restores the terminal.
+func (sc *serverConsole) Close() error {
+	return sc.con.Close()
+}
+
+// command handles a launcher command typed in the console.
+func (sc *serverConsole) command(line string) {
+	logger := sc.logger
+	name := strings.ToLower(strings.TrimSpace(line))
+	switch name {
+	case "restart":
+		logger.Info().Msgf("Restarting server...")
+		_ = sc.sess.request(context.Background(), actionRestart, false)
+	case "rebuild":
+		logger.Info().Msgf("Stopping server to rebuild it...")
+		_ = sc.sess.request(context.Background(), actionRebuild, false)
+	case "stop":
+		logger.Info().Msgf("Stopping server...")
+		_ = sc.sess.request(context.Background(), actionStop, false)
+	case "plugins":
+		logger.Info().Msgf("The server is running with the following plugins:")
+		for _, m := range sc.sess.status().Plugins {
+			version := m.Version
+			if m.Replace != "" {
+				version = m.Replace
+			}
+			logger.Info().Msgf("  %s %s", m.Module, version)
+		}
+	case "help", "":
+		logger.Info().Msgf("Lines typed are sent to the server. The following commands are handled by the launcher:")
+		logger.Info().Msgf("  %srestart  Restarts the server.", commandPrefix)
+		logger.Info().Msgf("  %srebuild  Rebuilds the server if saddle.toml or any plugin changed, and restarts it.", commandPrefix)
+		logger.Info().Msgf("  %splugins  Lists the plugins bundled with the server.", commandPrefix)
+		logger.Info().Msgf("  %sstop     Stops the server and the launcher.", commandPrefix)
+		logger.Info().Msgf("Start a line with '%s%s' to send it to the server with a single '%s'.", commandPrefix, commandPrefix, commandPrefix)
+	default:
+		logger.Error().Msgf("Unknown command '%s%s', type '%shelp' for a list of commands.", commandPrefix, name, commandPrefix)
+	}
+}
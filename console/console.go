@@ -0,0 +1,286 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Prompt is shown in front of the line that is being typed.
+const Prompt = "> "
+
+// maxHistory is the amount of lines kept in the history of the console.
+const maxHistory = 500
+
+// Console reads the lines typed by the user, while other output is written to the same terminal. If the input and
+// output are a terminal, the line being typed can be edited, previous lines can be recalled using the arrow keys, and
+// output is written above the line being typed. Otherwise, lines are read as they are.
+type Console struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	mu sync.Mutex
+	// restore restores the previous state of the terminal. It is nil if the console is not editing lines.
+	restore func() error
+	// line is the line being typed and pos the position of the cursor in it.
+	line []rune
+	pos  int
+	// partial is true if the last output written was not terminated by a newline, in which case the prompt is not
+	// shown.
+	partial bool
+	// history holds the previous lines. histPos is the index of the line shown from it, which is len(history) for the
+	// line being typed, which is kept in draft while browsing the history.
+	history []string
+	histPos int
+	draft   []rune
+}
+
+// New returns a console reading from in and writing to out. If both are a terminal and the platform supports it, the
+// terminal is switched to a mode in which the console edits lines itself. Close must be called to restore the
+// terminal.
+func New(in, out *os.File) *Console {
+	c := &Console{in: bufio.NewReader(in), out: out}
+	if isTerminal(in) && isTerminal(out) {
+		if restore, err := makeCbreak(int(in.Fd())); err == nil {
+			c.restore = restore
+			c.redraw()
+		}
+	}
+	return c
+}
+
+// Editing returns true if the console edits lines itself, rather than reading them as they are.
+func (c *Console) Editing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.restore != nil
+}
+
+// Close clears the prompt and restores the terminal to the state it was in before the console was created. It may be
+// called multiple times.
+func (c *Console) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.restore == nil {
+		return nil
+	}
+	if !c.partial {
+		_, _ = io.WriteString(c.out, "\r\033[K")
+	}
+	err := c.restore()
+	c.restore = nil
+	return err
+}
+
+// ReadLine reads the next line typed, without the newline. io.EOF is returned at the end of the input, or when ctrl+d
+// is pressed on an empty line.
+func (c *Console) ReadLine() (string, error) {
+	if !c.Editing() {
+		line, err := c.in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	for {
+		r, _, err := c.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		key := string(r)
+		if r == '\033' {
+			key, err = c.readEscape()
+			if err != nil {
+				return "", err
+			}
+		}
+		if line, done, err := c.handle(key); done {
+			return line, err
+		}
+	}
+}
+
+// readEscape reads the rest of an escape sequence after the escape character, such as "\033[A" for the up arrow.
+func (c *Console) readEscape() (string, error) {
+	seq := []rune{'\033'}
+	r, _, err := c.in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	seq = append(seq, r)
+	if r != '[' && r != 'O' {
+		return string(seq), nil
+	}
+	// The sequence ends with a character in the range '@' to '~', after any parameters.
+	for {
+		r, _, err = c.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, r)
+		if r >= '@' && r <= '~' {
+			return string(seq), nil
+		}
+	}
+}
+
+// handle handles a key pressed, which is either a single character or an escape sequence. If the line is finished, it
+// is returned with done set to true.
+func (c *Console) handle(key string) (line string, done bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.restore == nil {
+		// The console was closed while reading, so the line is returned as it is.
+		return string(c.line), true, nil
+	}
+	switch key {
+	case "\r", "\n":
+		line = string(c.line)
+		c.addHistory(line)
+		c.line, c.pos, c.histPos, c.draft = nil, 0, len(c.history), nil
+		_, _ = io.WriteString(c.out, "\r\n")
+		c.redraw()
+		return line, true, nil
+	case "\x04": // ctrl+d
+		if len(c.line) == 0 {
+			_, _ = io.WriteString(c.out, "\r\n")
+			return "", true, io.EOF
+		}
+		c.delete(c.pos, c.pos+1)
+	case "\x7f", "\x08": // backspace
+		c.delete(c.pos-1, c.pos)
+	case "\033[3~": // delete
+		c.delete(c.pos, c.pos+1)
+	case "\x01", "\033[H", "\033OH", "\033[1~", "\033[7~": // ctrl+a, home
+		c.pos = 0
+	case "\x05", "\033[F", "\033OF", "\033[4~", "\033[8~": // ctrl+e, end
+		c.pos = len(c.line)
+	case "\x02", "\033[D", "\033OD": // ctrl+b, left
+		if c.pos > 0 {
+			c.pos--
+		}
+	case "\x06", "\033[C", "\033OC": // ctrl+f, right
+		if c.pos < len(c.line) {
+			c.pos++
+		}
+	case "\x15": // ctrl+u
+		c.delete(0, c.pos)
+	case "\x0b": // ctrl+k
+		c.delete(c.pos, len(c.line))
+	case "\x17": // ctrl+w
+		start := c.pos
+		for start > 0 && unicode.IsSpace(c.line[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(c.line[start-1]) {
+			start--
+		}
+		c.delete(start, c.pos)
+	case "\x10", "\033[A", "\033OA": // ctrl+p, up
+		c.browse(-1)
+	case "\x0e", "\033[B", "\033OB": // ctrl+n, down
+		c.browse(1)
+	default:
+		r := []rune(key)
+		if len(r) != 1 || !unicode.IsPrint(r[0]) {
+			// Unknown control characters and escape sequences are ignored.
+			return "", false, nil
+		}
+		c.line = append(c.line[:c.pos], append([]rune{r[0]}, c.line[c.pos:]...)...)
+		c.pos++
+	}
+	c.redraw()
+	return "", false, nil
+}
+
+// delete removes the characters from start up to end from the line, moving the cursor to start.
+func (c *Console) delete(start, end int) {
+	if start < 0 || end > len(c.line) || start >= end {
+		return
+	}
+	c.line = append(c.line[:start], c.line[end:]...)
+	c.pos = start
+}
+
+// browse moves through the history by the offset, replacing the line being typed.
+func (c *Console) browse(offset int) {
+	pos := c.histPos + offset
+	if pos < 0 || pos > len(c.history) {
+		return
+	}
+	if c.histPos == len(c.history) {
+		c.draft = c.line
+	}
+	c.histPos = pos
+	if pos == len(c.history) {
+		c.line = c.draft
+	} else {
+		c.line = []rune(c.history[pos])
+	}
+	c.pos = len(c.line)
+}
+
+// addHistory adds a line to the history, unless it is empty or the same as the previous line.
+func (c *Console) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(c.history) > 0 && c.history[len(c.history)-1] == line) {
+		return
+	}
+	c.history = append(c.history, line)
+	if len(c.history) > maxHistory {
+		c.history = c.history[len(c.history)-maxHistory:]
+	}
+}
+
+// redraw draws the prompt and the line being typed, with the cursor at its position.
+func (c *Console) redraw() {
+	if c.partial {
+		_, _ = io.WriteString(c.out, "\r\n")
+		c.partial = false
+	}
+	s := "\r\033[K" + Prompt + string(c.line)
+	if back := len(c.line) - c.pos; back > 0 {
+		s += fmt.Sprintf("\033[%dD", back)
+	}
+	_, _ = io.WriteString(c.out, s)
+}
+
+// Writer returns a writer that writes output to w above the line being typed. w should write to the same terminal as
+// the console.
+func (c *Console) Writer(w io.Writer) io.Writer {
+	return writer{c: c, w: w}
+}
+
+// writer writes output above the line being typed in a console.
+type writer struct {
+	c *Console
+	w io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	c := w.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.restore == nil || len(p) == 0 {
+		return w.w.Write(p)
+	}
+	if !c.partial {
+		// The prompt is cleared, so that the output is written in its place.
+		_, _ = io.WriteString(c.out, "\r\033[K")
+	}
+	n, err := w.w.Write(p)
+	c.partial = p[len(p)-1] != '\n'
+	if !c.partial {
+		c.redraw()
+	}
+	return n, err
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package console
+
+import "errors"
+
+// makeCbreak is not supported on this platform, so lines are read as they are.
+func makeCbreak(int) (func() error, error) {
+	return nil, errors.New("editing lines is not supported on this platform")
+}
@@ -0,0 +1,25 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package console
+
+import "golang.org/x/sys/unix"
+
+// makeCbreak turns off echoing and line buffering of the terminal, so that the console can edit lines itself. Signals
+// such as ctrl+c are still handled by the terminal, and newlines written are still translated. It returns a function
+// that restores the previous state of the terminal.
+func makeCbreak(fd int) (func() error, error) {
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	t := *old
+	t.Lflag &^= unix.ECHO | unix.ICANON
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	if err = unix.IoctlSetTermios(fd, ioctlSetTermios, &t); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, old)
+	}, nil
+}
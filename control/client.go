@@ -0,0 +1,95 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrNotRunning is returned by the client if no launcher is listening on the control socket.
+var ErrNotRunning = errors.New("the launcher is not running, or its control socket is not enabled")
+
+// Client talks to the control API of a running launcher.
+type Client struct {
+	http *http.Client
+}
+
+// Dial returns a client for the control socket at the path. No connection is made until a request is sent.
+func Dial(path string) *Client {
+	return &Client{http: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}}
+}
+
+// Status returns the status of the launcher.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var s Status
+	body, err := c.do(ctx, http.MethodGet, "/status")
+	if err != nil {
+		return s, err
+	}
+	if err = json.Unmarshal(body, &s); err != nil {
+		return s, fmt.Errorf("invalid status received: %w", err)
+	}
+	return s, nil
+}
+
+// Restart restarts the server, returning once it was started again.
+func (c *Client) Restart(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/restart")
+	return err
+}
+
+// Rebuild rebuilds the server if anything changed, returning once it was started again.
+func (c *Client) Rebuild(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/rebuild")
+	return err
+}
+
+// Stop stops the server and the launcher, returning once the server stopped.
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/stop")
+	return err
+}
+
+// Log returns the last lines of the output of the server.
+func (c *Client) Log(ctx context.Context, lines int) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, "/log?lines="+url.QueryEscape(strconv.Itoa(lines)))
+}
+
+// do sends a request to the control socket and returns the body of the response.
+func (c *Client) do(ctx context.Context, method, path string) ([]byte, error) {
+	// The host is ignored, as the client always connects to the socket.
+	req, err := http.NewRequestWithContext(ctx, method, "http://saddle"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, ErrNotRunning
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,164 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Status describes the launcher and the server it runs.
+type Status struct {
+	// LauncherPID is the process ID of the launcher.
+	LauncherPID int `json:"launcher-pid"`
+	// Running is true if the server is running. If false, the launcher is restarting or rebuilding the server.
+	Running bool `json:"running"`
+	// PID is the process ID of the server, and Started the time at which it was started. Both describe the last server
+	// started if it is not running.
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Uptime is the amount of seconds that the server has been running.
+	Uptime float64 `json:"uptime"`
+	// Binary is the path of the server binary, and Fingerprint the fingerprint of the build, as in saddle.lock.
+	Binary      string `json:"binary"`
+	Fingerprint string `json:"fingerprint"`
+	// Api and Dragonfly are the versions of the Saddle API and dragonfly bundled with the server.
+	Api       string `json:"api"`
+	Dragonfly string `json:"dragonfly"`
+	// Plugins are the plugins bundled with the server.
+	Plugins []Plugin `json:"plugins"`
+}
+
+// Plugin is a plugin bundled with the server.
+type Plugin struct {
+	// Module is the path of the module of the plugin.
+	Module string `json:"module"`
+	// Version is the version of the module. It may be empty if the module is replaced by a local directory.
+	Version string `json:"version,omitempty"`
+	// Replace is the local directory that the module is replaced with, if any.
+	Replace string `json:"replace,omitempty"`
+}
+
+// Handler carries out the requests received on the control socket.
+type Handler interface {
+	// Status returns the current status of the launcher.
+	Status() Status
+	// Restart, Rebuild and Stop restart, rebuild or stop the server. They return once the action was carried out, or
+	// once the context is done.
+	Restart(ctx context.Context) error
+	Rebuild(ctx context.Context) error
+	Stop(ctx context.Context) error
+	// Log returns the last lines of the output of the server.
+	Log(lines int) []byte
+}
+
+// DefaultLogLines is the amount of lines of output returned by the log endpoint if no amount is requested.
+const DefaultLogLines = 100
+
+// Server serves the control API on a unix socket. The API has the following endpoints:
+//
+//	GET  /status        returns the Status as JSON.
+//	POST /restart       restarts the server.
+//	POST /rebuild       rebuilds the server if anything changed, and restarts it.
+//	POST /stop          stops the server and the launcher.
+//	GET  /log?lines=n   returns the last n lines of output of the server.
+//
+// Errors are returned with a status code other than 200, and the error message as the body.
+type Server struct {
+	path string
+	srv  *http.Server
+	ln   net.Listener
+}
+
+// Listen listens on a unix socket at the path, and serves the control API on it using the handler. The socket can only
+// be used by the current user. If a socket is left at the path by a launcher that did not exit cleanly, it is
+// replaced. An error is returned if another launcher is still listening on it.
+func Listen(path string, h Handler) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("error creating control socket directory: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("another launcher is already listening on '%s'", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale control socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on control socket: %w", err)
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("error restricting access to control socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(h.Status())
+	}))
+	for name, action := range map[string]func(context.Context) error{
+		"/restart": h.Restart,
+		"/rebuild": h.Rebuild,
+		"/stop":    h.Stop,
+	} {
+		action := action
+		mux.HandleFunc(name, method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			if err := action(r.Context()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}))
+	}
+	mux.HandleFunc("/log", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		lines := DefaultLogLines
+		if s := r.URL.Query().Get("lines"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "lines must be a positive number", http.StatusBadRequest)
+				return
+			}
+			lines = n
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(h.Log(lines))
+	}))
+
+	s := &Server{path: path, srv: &http.Server{Handler: mux}, ln: ln}
+	go func() {
+		_ = s.srv.Serve(ln)
+	}()
+	return s, nil
+}
+
+// method only allows requests using the HTTP method passed to the handler.
+func method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			http.Error(w, fmt.Sprintf("method %s not allowed, use %s", r.Method, m), http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// Close stops serving the control API, giving requests that are being handled a few seconds to finish, and removes
+// the socket.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.srv.Shutdown(ctx)
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/launcher"
+	"github.com/saddlemc/launcher/logs"
+	"io"
+	"time"
+)
+
+// crashCapture keeps the last lines of the output of the server in memory, so that they can be included in a crash
+// bundle if the server crashes.
+type crashCapture struct {
+	output, stderr *logs.Tail
+}
+
+// newCrashCapture returns a crashCapture keeping launcher.CrashOutputLines lines of output.
+func newCrashCapture() crashCapture {
+	return crashCapture{
+		output: logs.NewTail(launcher.CrashOutputLines),
+		stderr: logs.NewTail(launcher.CrashOutputLines),
+	}
+}
+
+// tee returns writers that write to stdout and stderr, and keep the output in memory as well.
+func (c crashCapture) tee(stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	return io.MultiWriter(stdout, c.output), io.MultiWriter(stderr, c.output, c.stderr)
+}
+
+// reset removes the output kept, so that a new run of the server starts empty.
+func (c crashCapture) reset() {
+	c.output.Reset()
+	c.stderr.Reset()
+}
+
+// writeCrashBundle writes a crash bundle with the captured output and returns its path. If the bundle could not be
+// written, an empty string is returned.
+func writeCrashBundle(logger *zerolog.Logger, plan launcher.Plan, c crashCapture) string {
+	path, err := launcher.WriteCrashBundle(plan.Options.Config, configPath, launcher.Crash{
+		Output:  c.output.Bytes(),
+		Stderr:  c.stderr.Bytes(),
+		Time:    time.Now(),
+		Modules: plan.Settings.Modules,
+	})
+	if err != nil {
+		logger.Error().Msgf("Unable to write crash bundle: %v", err)
+		return ""
+	}
+	return path
+}
+
+// reportPanic logs which bundled module the panic in the captured output originated in, if it can be found.
+func reportPanic(logger *zerolog.Logger, plan launcher.Plan, c crashCapture) {
+	trace := launcher.PanicTrace(c.stderr.Bytes())
+	a, ok := launcher.AttributePanic(trace, plan.Settings.Modules)
+	if !ok {
+		return
+	}
+	logger.Error().Str("module", a.Module.Name).Msgf("The panic originated in %s.", a)
+	if a.Plugin {
+		logger.Error().Msgf("The issue can be reported to the plugin at %s.", a.Repository())
+	} else {
+		logger.Error().Msgf("The issue may be caused by a plugin, or can be reported at %s.", a.Repository())
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/control"
+	"os"
+	"time"
+)
+
+// status returns the status of the session for the control socket.
+func (s *session) status() control.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := control.Status{
+		LauncherPID: os.Getpid(),
+		Running:     s.running,
+		PID:         s.pid,
+		Started:     s.started,
+		Binary:      s.binary,
+		Fingerprint: s.plan.Lock.Fingerprint,
+		Api:         s.plan.Lock.Api,
+		Dragonfly:   s.plan.Lock.Dragonfly,
+		Plugins:     []control.Plugin{},
+	}
+	if s.running {
+		st.Uptime = time.Since(s.started).Seconds()
+	}
+	for _, m := range s.plan.Settings.Modules {
+		if m.Name == "github.com/df-mc/dragonfly" || m.Name == "github.com/saddlemc/saddle" {
+			continue
+		}
+		st.Plugins = append(st.Plugins, control.Plugin{Module: m.Name, Version: m.Version, Replace: m.Replace})
+	}
+	return st
+}
+
+// controlHandler handles the requests received on the control socket for a session.
+type controlHandler struct {
+	logger *zerolog.Logger
+	sess   *session
+}
+
+func (h controlHandler) Status() control.Status {
+	return h.sess.status()
+}
+
+func (h controlHandler) Restart(ctx context.Context) error {
+	h.logger.Info().Msgf("Restarting server, as requested through the control socket...")
+	return h.sess.request(ctx, actionRestart, true)
+}
+
+func (h controlHandler) Rebuild(ctx context.Context) error {
+	h.logger.Info().Msgf("Stopping server to rebuild it, as requested through the control socket...")
+	return h.sess.request(ctx, actionRebuild, true)
+}
+
+func (h controlHandler) Stop(ctx context.Context) error {
+	h.logger.Info().Msgf("Stopping server, as requested through the control socket...")
+	return h.sess.request(ctx, actionStop, true)
+}
+
+func (h controlHandler) Log(lines int) []byte {
+	return h.sess.output.Last(lines)
+}
+
+// ctlCommand talks to the control socket of a running launcher.
+func ctlCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+	flagSocket := flags.String("socket", "",
+		"The path of the control socket. If empty, the socket set in saddle.toml is used.",
+	)
+	flagJSON := flags.Bool("json", false,
+		"If set to true, the status is printed as JSON.",
+	)
+	flagLines := flags.Int("n", control.DefaultLogLines,
+		"The amount of lines of output shown by 'log'.",
+	)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: saddle ctl [flags] <status|restart|rebuild|stop|log>\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	socket := *flagSocket
+	if socket == "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			logger.Fatal().Msgf("Unable to read the control socket from saddle.toml: %v", err)
+		}
+		if !cfg.Control.Enabled {
+			logger.Warn().Msgf("The control socket is not enabled in saddle.toml, set 'enabled' in the [control] section.")
+		}
+		socket = cfg.Control.Socket
+	}
+	client := control.Dial(socket)
+
+	// Restarting and rebuilding wait for the server to be started again, which may take a while.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	var err error
+	switch cmd := flags.Arg(0); cmd {
+	case "status":
+		var st control.Status
+		if st, err = client.Status(ctx); err == nil {
+			printStatus(st, *flagJSON)
+		}
+	case "restart":
+		if err = client.Restart(ctx); err == nil {
+			logger.Info().Msgf("The server was restarted.")
+		}
+	case "rebuild":
+		if err = client.Rebuild(ctx); err == nil {
+			logger.Info().Msgf("The server was rebuilt and restarted.")
+		}
+	case "stop":
+		if err = client.Stop(ctx); err == nil {
+			logger.Info().Msgf("The server was stopped.")
+		}
+	case "log":
+		var out []byte
+		if out, err = client.Log(ctx, *flagLines); err == nil {
+			_, _ = os.Stdout.Write(out)
+		}
+	default:
+		logger.Fatal().Msgf("Unknown command '%s', use status, restart, rebuild, stop or log.", cmd)
+	}
+	if errors.Is(err, control.ErrNotRunning) {
+		logger.Fatal().Msgf("Unable to connect to '%s': %v.", socket, err)
+	} else if err != nil {
+		logger.Fatal().Msgf("Request failed: %v", err)
+	}
+}
+
+// printStatus prints the status of a launcher, either for humans or as JSON.
+func printStatus(st control.Status, asJSON bool) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(st)
+		return
+	}
+	state := "running"
+	if !st.Running {
+		state = "not running (restarting or rebuilding)"
+	}
+	fmt.Printf("Server:      %s\n", state)
+	fmt.Printf("PID:         %d (launcher %d)\n", st.PID, st.LauncherPID)
+	if st.Running {
+		fmt.Printf("Uptime:      %s (since %s)\n", (time.Duration(st.Uptime) * time.Second).String(), st.Started.Format(time.RFC3339))
+	}
+	fmt.Printf("Binary:      %s\n", st.Binary)
+	fmt.Printf("Fingerprint: %s\n", st.Fingerprint)
+	fmt.Printf("Saddle API:  %s\n", st.Api)
+	fmt.Printf("Dragonfly:   %s\n", st.Dragonfly)
+	fmt.Printf("Plugins:\n")
+	for _, p := range st.Plugins {
+		version := p.Version
+		if p.Replace != "" {
+			version = p.Replace
+		}
+		fmt.Printf("  %s %s\n", p.Module, version)
+	}
+}
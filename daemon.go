@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/control"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startCommand runs the launcher like the run command. With -detach, the launcher is started in the background
+// instead, writing its process ID to the pid file and its output to the log file set in saddle.toml.
+func startCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("start", flag.ExitOnError)
+	addRunFlags(flags)
+	flagDetach := flags.Bool("detach", false,
+		"If set to true, the launcher and server are started in the background. Use 'saddle stop' to stop them.",
+	)
+	_ = flags.Parse(args)
+	// Only the flags of the run command are passed on, as it does not know about -detach.
+	var runArgs []string
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "detach" {
+			runArgs = append(runArgs, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+	runArgs = append(runArgs, flags.Args()...)
+	if !*flagDetach {
+		runCommand(logger, runArgs)
+		return
+	}
+
+	cfg := loadConfig(logger)
+	pidFile, logFile := cfg.Daemon.PidFile, cfg.Daemon.LogFile
+	if pid, running := checkPidFile(logger, pidFile); running {
+		logger.Fatal().Msgf("The launcher is already running in the background (pid %d). Use 'saddle stop' to stop it.", pid)
+	}
+
+	// The launcher runs itself again in the background, passing on all flags. The console is disabled, as there is no
+	// terminal to read from.
+	childArgs := append([]string{"run", "-no-console", "-pid-file", pidFile}, runArgs...)
+	for name, value := range map[string]string{"log-format": logging.format, "log-level": logging.level, "log-file": logging.file} {
+		if value != "" {
+			childArgs = append(childArgs, fmt.Sprintf("-%s=%s", name, value))
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		logger.Fatal().Msgf("Unable to find launcher executable: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		logger.Fatal().Msgf("Unable to create log directory: %v", err)
+	}
+	out, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Fatal().Msgf("Unable to open log file: %v", err)
+	}
+	defer out.Close()
+	attr, err := detachAttr()
+	if err != nil {
+		logger.Fatal().Msgf("Unable to start the launcher in the background: %v", err)
+	}
+	cmd := exec.Command(executable, childArgs...)
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.SysProcAttr = attr
+	if err = cmd.Start(); err != nil {
+		logger.Fatal().Msgf("Unable to start the launcher in the background: %v", err)
+	}
+
+	// The launcher writes the pid file itself once it started, so it is waited for to know that it is running.
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-exited:
+			logger.Fatal().Msgf("The launcher stopped right after starting, see '%s' for its output.", logFile)
+		case <-timeout:
+			logger.Fatal().Msgf("The launcher did not write its pid file in time, see '%s' for its output.", logFile)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if pid, _, _ := readPidFile(pidFile); pid == cmd.Process.Pid {
+			break
+		}
+	}
+	logger.Info().Msgf("Started the launcher in the background (pid %d), its output is written to '%s'.", cmd.Process.Pid, logFile)
+	logger.Info().Msgf("Use 'saddle status' to check on it and 'saddle stop' to stop it.")
+}
+
+// stopCommand stops a launcher running in the background, and waits for it to exit.
+func stopCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("stop", flag.ExitOnError)
+	flagTimeout := flags.Duration("timeout", 30*time.Second,
+		"The time to wait for the launcher to stop.",
+	)
+	_ = flags.Parse(args)
+
+	pidFile := loadConfig(logger).Daemon.PidFile
+	pid, running := checkPidFile(logger, pidFile)
+	if !running {
+		logger.Info().Msgf("The launcher is not running in the background.")
+		return
+	}
+
+	// The launcher stops the server gracefully when it is terminated, like it does when ctrl+c is pressed.
+	logger.Info().Msgf("Stopping launcher %d...", pid)
+	if err := terminate(pid); err != nil {
+		logger.Fatal().Msgf("Unable to stop launcher: %v", err)
+	}
+	deadline := time.Now().Add(*flagTimeout)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			logger.Fatal().Msgf("The launcher did not stop within %s.", *flagTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// The launcher removes the pid file itself, unless it exited unexpectedly.
+	_ = os.Remove(pidFile)
+	logger.Info().Msgf("The launcher was stopped.")
+}
+
+// statusCommand shows if a launcher is running in the background. If its control socket is enabled, the status of the
+// server is shown as well.
+func statusCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	_ = flags.Parse(args)
+
+	cfg := loadConfig(logger)
+	pid, running, err := readPidFile(cfg.Daemon.PidFile)
+	if errors.Is(err, errInvalidPidFile) {
+		logger.Warn().Msgf("The %v.", err)
+	} else if err != nil {
+		logger.Fatal().Msgf("Unable to read pid file: %v", err)
+	}
+	if !running {
+		if pid != 0 {
+			logger.Warn().Msgf("The pid file refers to launcher %d, which is no longer running.", pid)
+		}
+		logger.Info().Msgf("The launcher is not running in the background.")
+		os.Exit(3)
+	}
+	since := ""
+	if stat, err := os.Stat(cfg.Daemon.PidFile); err == nil {
+		since = fmt.Sprintf(" since %s", stat.ModTime().Format(time.RFC3339))
+	}
+	logger.Info().Msgf("The launcher is running in the background (pid %d)%s.", pid, since)
+	if !cfg.Control.Enabled {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	st, err := control.Dial(cfg.Control.Socket).Status(ctx)
+	if err != nil {
+		logger.Warn().Msgf("Unable to get the status of the server from the control socket: %v", err)
+		return
+	}
+	printStatus(st, false)
+}
+
+// errInvalidPidFile is returned by readPidFile if the pid file does not contain a process ID.
+var errInvalidPidFile = errors.New("does not contain a process ID")
+
+// readPidFile reads the process ID in the pid file, and returns if that process is still running. If the file does not
+// exist, a process ID of 0 is returned.
+func readPidFile(path string) (int, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false, fmt.Errorf("pid file '%s' %w", path, errInvalidPidFile)
+	}
+	return pid, processRunning(pid), nil
+}
+
+// checkPidFile reads the pid file like readPidFile, exiting if it could not be read. A pid file that refers to a
+// launcher that is no longer running, or that does not contain a process ID, is stale and is removed.
+func checkPidFile(logger *zerolog.Logger, path string) (int, bool) {
+	pid, running, err := readPidFile(path)
+	if errors.Is(err, errInvalidPidFile) {
+		logger.Warn().Msgf("Removing stale pid file, as it %v.", errInvalidPidFile)
+		_ = os.Remove(path)
+		return 0, false
+	} else if err != nil {
+		logger.Fatal().Msgf("Unable to read pid file: %v", err)
+	}
+	if !running && pid != 0 {
+		logger.Warn().Msgf("Removing stale pid file of launcher %d, which is no longer running.", pid)
+		_ = os.Remove(path)
+		return 0, false
+	}
+	return pid, running
+}
+
+// writePidFile writes the process ID of the launcher to the pid file. An error is returned if the file holds the
+// process ID of another launcher that is still running. A stale pid file is replaced.
+func writePidFile(path string) error {
+	if pid, running, _ := readPidFile(path); running && pid != os.Getpid() {
+		return fmt.Errorf("another launcher is already running (pid %d)", pid)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// The file is written in one go, so that it is never read while only partially written.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removePidFile removes the pid file, if it still holds the process ID of the launcher.
+func removePidFile(path string) {
+	if pid, _, _ := readPidFile(path); pid == os.Getpid() {
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// detachAttr returns the attributes for starting the launcher in the background. It is started in a new session, so
+// that it is not stopped when the terminal is closed.
+func detachAttr() (*syscall.SysProcAttr, error) {
+	return &syscall.SysProcAttr{Setsid: true}, nil
+}
+
+// processRunning returns true if a process with the process ID is running.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means that the process exists, but belongs to another user.
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminate asks the process to stop gracefully.
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
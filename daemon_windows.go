@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errDetachUnsupported is returned when the launcher is started in the background on Windows, where this is not
+// supported. A service manager should be used instead.
+var errDetachUnsupported = errors.New("running in the background is not supported on Windows")
+
+// detachAttr returns errDetachUnsupported, as processes can not be detached from the console in the same way.
+func detachAttr() (*syscall.SysProcAttr, error) {
+	return nil, errDetachUnsupported
+}
+
+// processRunning returns true if a process with the process ID is running.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// terminate returns errDetachUnsupported, as processes can not be stopped gracefully.
+func terminate(int) error {
+	return errDetachUnsupported
+}
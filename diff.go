@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unifiedDiff returns the differences between two texts in the unified diff format, with three lines of context around
+// every change. An empty string is returned if the texts are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+	// The longest common subsequence of lines is computed from the end, so that the edits can be read from the start.
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// edit is a single line of the diff. Its kind is ' ', '-' or '+'.
+	type edit struct {
+		kind byte
+		line string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i]})
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', x[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', y[j]})
+			j++
+		}
+	}
+
+	const context = 3
+	var sb strings.Builder
+	// lineA and lineB are the line numbers in both texts of the edit at index k, starting at 1.
+	lineA, lineB := 1, 1
+	for k := 0; k < len(edits); {
+		if edits[k].kind == ' ' {
+			lineA, lineB, k = lineA+1, lineB+1, k+1
+			continue
+		}
+		// A hunk starts a few lines before the change, and ends once there are more unchanged lines than fit in the
+		// context of two hunks.
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+		end, unchanged := k, 0
+		for end < len(edits) && unchanged <= 2*context {
+			if edits[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		if unchanged > context {
+			end -= unchanged - context
+		}
+
+		startA, startB := lineA-(k-start), lineB-(k-start)
+		var countA, countB int
+		var body strings.Builder
+		for _, e := range edits[start:end] {
+			if e.kind != '+' {
+				countA++
+			}
+			if e.kind != '-' {
+				countB++
+			}
+			body.WriteString(string(e.kind) + strings.TrimSuffix(e.line, "\n") + "\n")
+		}
+		if sb.Len() == 0 {
+			sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", nameA, nameB))
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB))
+		sb.WriteString(body.String())
+
+		for _, e := range edits[k:end] {
+			if e.kind != '+' {
+				lineA++
+			}
+			if e.kind != '-' {
+				lineB++
+			}
+		}
+		k = end
+	}
+	return sb.String()
+}
+
+// splitLines splits a text into lines, keeping the line endings.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
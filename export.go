@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+)
+
+// exportCommand writes the generated server project to a directory, so that it can be opened in an editor, debugged or
+// built by other tools.
+func exportCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	bf := buildFlags{
+		out:       new(string),
+		recompile: new(bool),
+		offline: flags.Bool("offline", false,
+			"If set to true, no network access is used. The project uses the versions pinned in saddle.lock.",
+		),
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: saddle export [-offline] <dir>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	plan := resolvePlan(ctx, logger, bf)
+	logger.Info().Msgf("Exporting server to '%s'...", flags.Arg(0))
+	err := launcher.Export(ctx, plan, flags.Arg(0))
+	_ = plan.Close()
+	if err != nil {
+		logger.Fatal().Msgf("Could not export server: %v", err)
+	}
+	logger.Info().Msgf("Done! The server project was exported to '%s'.", flags.Arg(0))
+}
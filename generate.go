@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// generateCommand generates files for running the server outside the launcher, such as a systemd unit or a
+// Dockerfile.
+func generateCommand(logger *zerolog.Logger, args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: saddle generate systemd [-o file] [-name name] [-user user] [-launcher] [-timeout duration]")
+		fmt.Fprintln(os.Stderr, "       saddle generate docker [-o file] [-launcher-version version]")
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	var (
+		data []byte
+		err  error
+		out  *string
+		// kind describes the file generated in messages.
+		kind string
+	)
+	switch args[0] {
+	case "systemd":
+		kind = "systemd unit"
+		flags := flag.NewFlagSet("generate systemd", flag.ExitOnError)
+		out = flags.String("o", "", "The file to write the unit to, or '-' to print it. Defaults to <name>.service.")
+		flagName := flags.String("name", "saddle", "The name of the unit.")
+		flagUser := flags.String("user", "", "The user that the server runs as. If empty, no user is set.")
+		flagLauncher := flags.Bool("launcher", false,
+			"If set to true, the unit runs the launcher, which rebuilds the server when needed, instead of the "+
+				"server binary.",
+		)
+		flagTimeout := flags.Duration("timeout", 10*time.Second, "The time the server gets to shut down.")
+		_ = flags.Parse(args[1:])
+		if *out == "" {
+			*out = *flagName + ".service"
+		}
+
+		opts := launcher.SystemdOptions{Name: *flagName, User: *flagUser, ShutdownTimeout: *flagTimeout}
+		if *flagLauncher {
+			if opts.Launcher, err = os.Executable(); err != nil {
+				logger.Fatal().Msgf("Unable to find launcher executable: %v", err)
+			}
+		}
+		data, err = launcher.Systemd(loadConfig(logger), opts)
+	case "docker":
+		kind = "Dockerfile"
+		flags := flag.NewFlagSet("generate docker", flag.ExitOnError)
+		out = flags.String("o", "Dockerfile", "The file to write the Dockerfile to, or '-' to print it.")
+		flagVersion := flags.String("launcher-version", launcherVersion(),
+			"The version of the launcher that is installed in the image to build the server.",
+		)
+		_ = flags.Parse(args[1:])
+
+		cfg := loadConfig(logger)
+		warnDockerContext(logger, cfg.Go.Netrc, cfg.Server.ApiReplace, cfg.Server.DragonflyReplace, cfg.Plugin)
+		data, err = launcher.Dockerfile(cfg, launcher.DockerOptions{LauncherVersion: *flagVersion})
+	default:
+		usage()
+	}
+	if err != nil {
+		logger.Fatal().Msgf("Could not generate %s: %v", kind, err)
+	}
+	if *out == "-" {
+		fmt.Print(string(data))
+		return
+	}
+	if err = os.WriteFile(*out, data, 0644); err != nil {
+		logger.Fatal().Msgf("Could not write '%s': %v", *out, err)
+	}
+	logger.Info().Msgf("Wrote %s to '%s'.", kind, *out)
+}
+
+// launcherVersion returns the version of the launcher as installed by 'go install', or "latest" if it was built from
+// a local checkout with changes, as that version can not be installed.
+func launcherVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" &&
+		!strings.Contains(info.Main.Version, "+") {
+		return info.Main.Version
+	}
+	return "latest"
+}
+
+// warnDockerContext warns about settings that point outside the directory of saddle.toml, which is used as the build
+// context of the Dockerfile, as they are not available while building the image.
+func warnDockerContext(logger *zerolog.Logger, netrc, apiReplace, dfReplace string, plugins []map[string]any) {
+	if netrc != "" {
+		logger.Warn().Msgf("The netrc file '%s' is not available while building the image, pass it as a secret instead.", netrc)
+	}
+	paths := []string{apiReplace, dfReplace}
+	for _, p := range plugins {
+		if local, ok := p["local"].(string); ok {
+			paths = append(paths, local)
+		}
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if rel := filepath.Clean(p); filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			logger.Warn().Msgf("'%s' is outside the build context, so it is not available while building the image.", p)
+		}
+	}
+}
@@ -3,15 +3,13 @@ module github.com/saddlemc/launcher
 go 1.19
 
 require (
-	github.com/kr/pretty v0.3.0
 	github.com/pelletier/go-toml/v2 v2.0.5
 	github.com/rogpeppe/go-internal v1.6.1
 	github.com/rs/zerolog v1.28.0
+	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab
 )
 
 require (
-	github.com/kr/text v0.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
-	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
 )
@@ -0,0 +1,188 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"github.com/saddlemc/launcher/bundler"
+	"github.com/saddlemc/launcher/cache"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/toolchain"
+	"io"
+	"os"
+	"time"
+)
+
+// Result describes the outcome of Build.
+type Result struct {
+	// Binary is the absolute path of the server binary.
+	Binary string
+	// Lock is the lock file describing the server binary.
+	Lock config.LockFile
+	// Installed is true if a new server binary replaced the previous one. If false, the server was already
+	// up-to-date.
+	Installed bool
+	// Cached is true if the new server binary was restored from the build cache instead of being built.
+	Cached bool
+	// Duration is the time it took to build the server.
+	Duration time.Duration
+}
+
+// Build builds the server described by the plan if it is outdated, or always if the plan was resolved with Recompile
+// set. The new server binary replaces the previous one atomically, and the previous one is stored in the build history.
+// If the build fails, the previous server binary is left untouched.
+func Build(ctx context.Context, p Plan) (Result, error) {
+	logger, cfg := p.Options.phaseLogger("build"), p.Options.Config
+	recompile := p.Options.Recompile
+	res := Result{Binary: p.Binary, Lock: p.Lock}
+
+	// If the lockfile could not successfully be loaded we rebuild the server regardless.
+	needsRebuilding := !p.PreviousOK
+	if p.Lock.Fingerprint != p.Previous.Fingerprint {
+		logger.Debug().Msgf("Build fingerprint changed from '%s' to '%s'.", p.Previous.Fingerprint, p.Lock.Fingerprint)
+		needsRebuilding = true
+	}
+	if needsRebuilding && !recompile && RolledBack(p.Lock.Fingerprint) {
+		// The user explicitly rolled back from a server built with these exact inputs, so it is not built again.
+		logger.Warn().Msgf("Not rebuilding the server, as this build was rolled back. Use '-recompile' to build it anyway.")
+		needsRebuilding = false
+	}
+
+	// Check if the file exist and can be accessed. If the file does not exist, we always have to rebuild the server.
+	if _, err := os.Stat(p.Binary); os.IsNotExist(err) {
+		logger.Debug().Msgf("No server binary detected, force rebuilding server.")
+		needsRebuilding = true
+	} else if err != nil {
+		logger.Error().Msgf("Unable to access output location: %s", err)
+	}
+	// New server binaries are never written to the output location directly. Instead, they are first placed at a
+	// staging location, and only once they are complete are they swapped in. This way, a failed or interrupted build
+	// always leaves the previous server in place.
+	staged := p.Binary + ".new"
+	buildCache, err := OpenCache(cfg)
+	if err != nil {
+		logger.Error().Msgf("Unable to open build cache, disabling build cache: %v", err)
+	}
+	history := OpenHistory(cfg)
+
+	// Before rebuilding, check if a server with the exact same inputs was built before. If so, it can simply be restored
+	// from the build cache.
+	if needsRebuilding && !recompile && buildCache != nil {
+		ok, err := buildCache.Get(p.Lock.Fingerprint, staged)
+		if err != nil {
+			logger.Error().Msgf("Unable to restore server from build cache: %v", err)
+		} else if ok {
+			logger.Info().Msgf("Restored server from build cache.")
+			if _, err := install(p.Options, history, p.Binary, staged, p.Lock); err != nil {
+				return res, err
+			}
+			res.Installed, res.Cached = true, true
+			return res, nil
+		}
+	}
+	// Rebuilt the server is there was an update or if the '--recompile' flag was passed.
+	if !needsRebuilding && !recompile {
+		return res, nil
+	}
+	logger.Info().Msgf("Rebuilding server...")
+	buildStart := time.Now()
+	// Create a temporary directory to build the server in.
+	temp, err := os.MkdirTemp("", "saddle_bundler_*")
+	if err != nil {
+		return res, fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	logger.Debug().Msgf("Created temporary directory '%s'.", temp)
+	// Be sure to remove the temporary directory after creating it.
+	defer os.RemoveAll(temp)
+
+	logger.Debug().Msgf("Bundling plugins...")
+	settings := p.Settings
+	settings.Path = temp
+	err = bundler.Bundle(settings)
+	if err != nil {
+		return res, fmt.Errorf("could not bundle plugins: %w", err)
+	}
+
+	logger.Debug().Msgf("Compiling server...")
+	cmd := p.Go.CommandContext(ctx, "mod", "tidy")
+	cmd.Dir = temp
+	cmd.Stderr = p.Options.output()
+	err = cmd.Run()
+	if err != nil {
+		return res, fmt.Errorf("could not resolve server dependencies, the previous server was kept: %w", err)
+	}
+	if err = compile(ctx, p.Go, p.Options.output(), temp, staged); err != nil {
+		return res, err
+	}
+	res.Duration = time.Since(buildStart)
+	logger.Info().Dur("duration", res.Duration).Msgf("Done! Finished building in %.3f seconds.", res.Duration.Seconds())
+
+	// The server has been built successfully. Now store the build information as the new lock file.
+	data, err := install(p.Options, history, p.Binary, staged, p.Lock)
+	if err != nil {
+		return res, err
+	}
+	res.Installed = true
+	if buildCache != nil {
+		logger.Debug().Msgf("Storing server in build cache...")
+		if err := buildCache.Put(p.Lock.Fingerprint, p.Binary, data); err != nil {
+			logger.Error().Msgf("Unable to store server in build cache: %v", err)
+		}
+	}
+	return res, nil
+}
+
+// OpenCache opens the build cache as configured. If the build cache is disabled, nil is returned.
+func OpenCache(cfg *config.Config) (*cache.Cache, error) {
+	if !cfg.Cache.Enabled {
+		return nil, nil
+	}
+	dir := cfg.Cache.Path
+	if dir == "" {
+		var err error
+		dir, err = cache.DefaultDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to locate build cache directory: %w", err)
+		}
+	}
+	return cache.New(dir, cfg.Cache.MaxSize*1024*1024), nil
+}
+
+// compile compiles the bundled server in dir to the staged path, writing the output of the compiler to output. Any
+// extra arguments are passed on to the go build command.
+func compile(ctx context.Context, gotool *toolchain.Go, output io.Writer, dir, staged string, args ...string) error {
+	cmd := gotool.CommandContext(ctx, append([]string{"build", "-o", staged}, args...)...)
+	cmd.Dir = dir
+	cmd.Stderr = output
+	err := cmd.Run()
+	if err != nil {
+		_ = os.Remove(staged)
+		return fmt.Errorf("could not compile server, the previous server was kept: %w", err)
+	}
+	return nil
+}
+
+// install atomically replaces the server binary at binary with the staged binary and writes the lock file that
+// belongs to it. The binary that is replaced is kept in the build history, together with its lock file, so that it can
+// be rolled back to later. The encoded lock file is returned.
+func install(opts Options, history *cache.History, binary, staged string, lock config.LockFile) ([]byte, error) {
+	logger := opts.phaseLogger("build")
+	if _, err := os.Stat(binary); err == nil {
+		logger.Debug().Msgf("Storing previous server in build history...")
+		previous, _ := os.ReadFile(LockPath)
+		if err := history.Push(binary, previous); err != nil {
+			logger.Error().Msgf("Unable to store previous server in build history: %v", err)
+		}
+	}
+	if err := os.Rename(staged, binary); err != nil {
+		return nil, fmt.Errorf("could not replace server binary: %w", err)
+	}
+	// A new server is installed, so any previous rollback no longer applies.
+	_ = os.Remove(rollbackMarker)
+
+	logger.Debug().Msgf("Writing saddle.lock...")
+	data, err := config.WriteLock(LockPath, lock)
+	if err != nil {
+		return nil, fmt.Errorf("could not write saddle.lock: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package launcher
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"github.com/saddlemc/launcher/config"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SystemdOptions configures the systemd unit generated by Systemd.
+type SystemdOptions struct {
+	// Name is the name of the unit, without the '.service' suffix.
+	Name string
+	// User is the user that the server runs as. If empty, the unit does not set a user.
+	User string
+	// Launcher is the absolute path of the launcher executable. If set, the unit runs the launcher, which rebuilds the
+	// server when needed before running it. Otherwise, the unit runs the server binary directly.
+	Launcher string
+	// ShutdownTimeout is the time the server gets to shut down before it is killed. If zero, the server gets 10
+	// seconds, like when it is run by the launcher.
+	ShutdownTimeout time.Duration
+}
+
+// Systemd generates a systemd unit that runs the server for the configuration. Like the launcher does, the server is
+// run in the directory of the server binary. If the unit runs the launcher, it is run in the current directory instead,
+// which must hold saddle.toml.
+func Systemd(cfg *config.Config, opts SystemdOptions) ([]byte, error) {
+	outFile, err := ServerPath(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = "saddle"
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
+	data := struct {
+		Name, Description, User, WorkingDirectory, ExecStart, KillSignal string
+		Launcher                                                         bool
+		TimeoutStopSec                                                   int
+	}{
+		Name:             opts.Name,
+		Description:      fmt.Sprintf("Saddle server in %s", filepath.Dir(outFile)),
+		User:             opts.User,
+		WorkingDirectory: filepath.Dir(outFile),
+		ExecStart:        systemdQuote(outFile),
+		// The server is interrupted when it is stopped, like the launcher does.
+		KillSignal:     "SIGINT",
+		TimeoutStopSec: int(opts.ShutdownTimeout.Seconds()),
+	}
+	if opts.Launcher != "" {
+		dir, err := filepath.Abs(".")
+		if err != nil {
+			return nil, fmt.Errorf("unable to get current working directory: %w", err)
+		}
+		data.Launcher = true
+		data.WorkingDirectory = dir
+		data.ExecStart = systemdQuote(opts.Launcher) + " run -no-console"
+		// The launcher needs time to stop the server after it is terminated, so it gets some time on top of that.
+		data.KillSignal = "SIGTERM"
+		data.TimeoutStopSec += 10
+	}
+	var buf bytes.Buffer
+	if err = systemdTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error writing systemd unit: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// systemdQuote quotes a path for use in a systemd command line if it contains spaces or quotes.
+func systemdQuote(s string) string {
+	if strings.ContainsAny(s, " \t\"'\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// DockerOptions configures the Dockerfile generated by Dockerfile.
+type DockerOptions struct {
+	// LauncherVersion is the version of the launcher installed to build the server, such as "v1.2.0". If empty, the
+	// latest version is used.
+	LauncherVersion string
+}
+
+// Dockerfile generates a multi-stage Dockerfile for the configuration. The first stage bundles the server using the
+// launcher, and the second stage only holds the server binary, which is run in a volume for its data.
+func Dockerfile(cfg *config.Config, opts DockerOptions) ([]byte, error) {
+	if opts.LauncherVersion == "" {
+		opts.LauncherVersion = "latest"
+	}
+	binary := strings.TrimSuffix(filepath.Base(cfg.Bundler.Path), ".exe")
+	if binary == "" || binary == "." || binary == string(filepath.Separator) {
+		binary = "server"
+	}
+	data := struct {
+		GoVersion, LauncherVersion, Binary string
+	}{
+		GoVersion:       cfg.Go.Version,
+		LauncherVersion: opts.LauncherVersion,
+		Binary:          binary,
+	}
+	var buf bytes.Buffer
+	if err := dockerTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error writing Dockerfile: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	//go:embed systemd.templ
+	systemdTemplateString string
+	systemdTemplate       = template.Must(template.New("systemd").Parse(systemdTemplateString))
+
+	//go:embed docker.templ
+	dockerTemplateString string
+	dockerTemplate       = template.Must(template.New("docker").Parse(dockerTemplateString))
+)
@@ -0,0 +1,421 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/bundler"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/plugin"
+	"github.com/saddlemc/launcher/plugin/provider"
+	"github.com/saddlemc/launcher/toolchain"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LockPath is the path of the lock file. Like all other relative paths used by the launcher, it is relative to the
+// working directory.
+const LockPath = "saddle.lock"
+
+// Options configures how a server is resolved and built.
+type Options struct {
+	// Config is the configuration of the server.
+	Config *config.Config
+	// Offline specifies if the network may not be used. If true, the server is built from the versions pinned in the
+	// lock file, using only modules that were downloaded before.
+	Offline bool
+	// Recompile specifies if the server should always be built, even if it is up-to-date.
+	Recompile bool
+	// Logger is used to report progress. If nil, nothing is logged.
+	Logger *zerolog.Logger
+	// Progress is called for each plugin before it is resolved, with a short label describing the plugin entry. The
+	// plugin reports its progress to the returned plugin.Progress. If nil, no progress is reported.
+	Progress func(label string) plugin.Progress
+	// Output is the writer that the output of go commands, such as compiler errors, is written to. If nil, it is
+	// written to os.Stderr.
+	Output io.Writer
+}
+
+// logger returns the logger of the options, or a logger that discards all messages if none was set.
+func (o Options) logger() *zerolog.Logger {
+	if o.Logger == nil {
+		l := zerolog.Nop()
+		return &l
+	}
+	return o.Logger
+}
+
+// output returns the writer that the output of go commands is written to.
+func (o Options) output() io.Writer {
+	if o.Output == nil {
+		return os.Stderr
+	}
+	return o.Output
+}
+
+// phaseLogger returns the logger of the options, adding a 'phase' field to every message, such as "resolve" or "build".
+func (o Options) phaseLogger(phase string) *zerolog.Logger {
+	l := o.logger().With().Str("phase", phase).Logger()
+	return &l
+}
+
+// progress returns the progress that a plugin entry should report to.
+func (o Options) progress(num int, info config.PluginInfo) plugin.Progress {
+	if o.Progress == nil {
+		return plugin.NopProgress{}
+	}
+	return o.Progress(entryLabel(num, info))
+}
+
+// entryLabel returns a short label describing a plugin entry, such as "#1 github.com/author/plugin".
+func entryLabel(num int, info config.PluginInfo) string {
+	for _, key := range []string{"module", "local", "provider"} {
+		if v, ok := info[key].(string); ok {
+			return fmt.Sprintf("#%d %s", num+1, v)
+		}
+	}
+	return fmt.Sprintf("#%d", num+1)
+}
+
+// Plan describes the server that should be built for a configuration. It is created by Resolve, and built by Build.
+type Plan struct {
+	// Options are the options that the plan was resolved with.
+	Options Options
+	// Go is the toolchain used to build the server.
+	Go *toolchain.Go
+	// Binary is the absolute path of the server binary.
+	Binary string
+	// Settings are the settings that the server should be bundled with. The path of the settings is not set.
+	Settings bundler.Settings
+	// Lock describes the server that should be built, and Previous describes the server that is currently built.
+	Lock, Previous config.LockFile
+	// PreviousOK is false if there was no valid lock file for the server that is currently built.
+	PreviousOK bool
+}
+
+// Close releases the resources held by the plan, such as temporary files holding credentials. The plan can no longer
+// be built after it is closed.
+func (p Plan) Close() error {
+	return p.Go.Close()
+}
+
+// UnavailableError is returned by Resolve if modules could not be found while running in offline mode.
+type UnavailableError struct {
+	// Unavailable contains a description for each module that could not be found.
+	Unavailable []string
+}
+
+// Error returns a message listing everything that is unavailable.
+func (e UnavailableError) Error() string {
+	return "not available in offline mode: " + strings.Join(e.Unavailable, ", ")
+}
+
+// Unwrap returns plugin.ErrUnavailableOffline.
+func (e UnavailableError) Unwrap() error {
+	return plugin.ErrUnavailableOffline
+}
+
+// Resolve resolves the versions of all plugins in the configuration and computes the fingerprint of the server that
+// should be built. Plugins that have changed since the last build are pulled. The returned plan must be closed once it
+// is no longer used.
+func Resolve(ctx context.Context, opts Options) (Plan, error) {
+	logger, cfg := opts.phaseLogger("resolve"), opts.Config
+	binary, err := ServerPath(cfg)
+	if err != nil {
+		return Plan{}, err
+	}
+	gotool, err := FindGo(cfg)
+	if err != nil {
+		return Plan{}, err
+	}
+	logger.Debug().Msgf("Using %s at '%s'.", gotool.Version, gotool.Path)
+	p := Plan{Options: opts, Go: gotool, Binary: binary}
+	if opts.Offline {
+		gotool.UseOffline()
+	}
+	if err = p.resolve(ctx); err != nil {
+		_ = p.Close()
+		return Plan{}, err
+	}
+	return p, nil
+}
+
+// resolve resolves the plan after its toolchain has been set up.
+func (p *Plan) resolve(ctx context.Context) error {
+	logger, cfg := p.Options.phaseLogger("resolve"), *p.Options.Config
+
+	logger.Debug().Msgf("Reading saddle.lock...")
+	// Get the current lockfile and also make a new lockfile. After checking plugin versions, the fingerprints of the
+	// two will be compared to see if the already present executable is outdated.
+	var err error
+	p.Previous, p.PreviousOK, err = config.LoadLock(LockPath)
+	if errors.Is(err, config.ErrInvalidLock) {
+		logger.Error().Msgf("Error trying to parse saddle.lock: %v. Using an empty saddle.lock file.", err)
+	} else if err != nil {
+		return fmt.Errorf("error reading saddle.lock: %w", err)
+	}
+	opts := provider.Options{
+		Go:      p.Go,
+		Offline: p.Options.Offline,
+		Pinned:  pinnedVersions(p.Previous),
+	}
+	if p.Options.Recompile && !p.Options.Offline {
+		// Recompiling resolves versions such as "latest" again, which is how plugins following a branch are updated.
+		opts.Pinned = nil
+	}
+
+	// unavailable holds everything that could not be found while running in offline mode. These are all reported at
+	// once, so that the user knows exactly what to download.
+	var unavailable []string
+	// Versions such as "latest" for dragonfly and the saddle API are resolved to an exact version as well, unless they
+	// are replaced by a local directory.
+	for _, m := range []struct {
+		name             string
+		version, replace *string
+	}{
+		{name: "github.com/df-mc/dragonfly", version: &cfg.Server.Dragonfly, replace: &cfg.Server.DragonflyReplace},
+		{name: "github.com/saddlemc/saddle", version: &cfg.Server.Api, replace: &cfg.Server.ApiReplace},
+	} {
+		if *m.replace != "" {
+			continue
+		}
+		version, err := provider.ResolveModule(ctx, opts, m.name, *m.version)
+		if errors.Is(err, plugin.ErrUnavailableOffline) {
+			unavailable = append(unavailable, err.Error())
+		} else if err != nil {
+			return fmt.Errorf("error fetching latest version of %s: %w", m.name, err)
+		}
+		*m.version = version
+	}
+
+	logger.Debug().Msgf("Parsing plugins...")
+	plugins, err := plugin.Parse(cfg.Plugin, provider.Providers(opts))
+	if err != nil {
+		return fmt.Errorf("error parsing plugins: %w", err)
+	}
+
+	p.Lock = config.LockFile{
+		Version:   config.LockVersion,
+		Api:       cfg.Server.Api,
+		Dragonfly: cfg.Server.Dragonfly,
+		Plugins:   map[string]string{},
+	}
+	pluginModules := make([]plugin.Module, 0, len(plugins))
+	for num, pl := range plugins {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress := p.Options.progress(num, cfg.Plugin[num])
+		start := time.Now()
+		latest, err := pl.Latest(ctx, progress)
+		if errors.Is(err, plugin.ErrUnavailableOffline) {
+			unavailable = append(unavailable, fmt.Sprintf("plugin entry #%d: %v", num+1, err))
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error fetching latest version for plugin entry #%d: %w", num+1, err)
+		}
+		pluginLogger := logger.With().Str("plugin", entryLabel(num, cfg.Plugin[num])).Str("module", latest.Module).Logger()
+		pluginLogger.Debug().Dur("duration", time.Since(start)).Msgf("Resolved plugin to '%s'.", latest.Checksum)
+		if x, ok := p.Previous.Plugins[latest.Module]; !ok || x != latest.Checksum {
+			start = time.Now()
+			err = pl.Pull(ctx, progress)
+			if err != nil {
+				return fmt.Errorf("error updating plugin entry #%d: %w", num+1, err)
+			}
+			pluginLogger.Debug().Dur("duration", time.Since(start)).Msgf("Updated plugin.")
+		}
+
+		p.Lock.Plugins[latest.Module] = latest.Checksum
+		pluginModules = append(pluginModules, pl.Module())
+	}
+	if len(unavailable) > 0 {
+		return UnavailableError{Unavailable: unavailable}
+	}
+
+	// The fingerprint covers every input of the build, so any change to the plugins, the server versions, the local
+	// replacements, the toolchain or the launcher's own templates will cause it to differ from the one in the lock.
+	logger.Debug().Msgf("Computing build fingerprint...")
+	p.Settings, err = bundleSettings(&cfg, pluginModules)
+	if err != nil {
+		return err
+	}
+	inputs, err := buildInputs(p.Go, &cfg, p.Lock)
+	if err != nil {
+		return err
+	}
+	p.Lock.Fingerprint, err = bundler.Fingerprint(p.Settings, inputs)
+	if err != nil {
+		return fmt.Errorf("error computing build fingerprint: %w", err)
+	}
+	return nil
+}
+
+// ServerPath returns the absolute path of the server binary.
+func ServerPath(cfg *config.Config) (string, error) {
+	outFile, err := filepath.Abs(cfg.Bundler.Path)
+	if err != nil {
+		return "", fmt.Errorf("unable to get current working directory: %w", err)
+	}
+	// On Windows, a '.exe' is required after the server executable.
+	if runtime.GOOS == "windows" {
+		if strings.ToLower(filepath.Ext(outFile)) != ".exe" {
+			outFile += ".exe"
+		}
+	}
+	return outFile, nil
+}
+
+// FindGo locates the Go toolchain as configured and makes sure it is able to build the server. The toolchain is set up
+// to use the configured settings for private modules, and must be closed once it is no longer used.
+func FindGo(cfg *config.Config) (*toolchain.Go, error) {
+	gotool, err := toolchain.Find(cfg.Go.Path, cfg.Go.Root)
+	if err != nil {
+		return nil, fmt.Errorf("go is required to build the server, but %w", err)
+	}
+	if err = gotool.Check(cfg.Go.Version); err != nil {
+		return nil, fmt.Errorf("unsupported go installation: %w", err)
+	}
+
+	private := toolchain.Private{
+		Proxy:     cfg.Go.Proxy,
+		Private:   cfg.Go.Private,
+		NoSumDB:   cfg.Go.NoSumDB,
+		Insecure:  cfg.Go.Insecure,
+		NetrcFile: cfg.Go.Netrc,
+	}
+	if cfg.Go.NetrcEnv != "" {
+		if cfg.Go.Netrc != "" {
+			return nil, errors.New("only one of 'netrc' and 'netrc-env' may be set in the [go] section of saddle.toml")
+		}
+		data, ok := os.LookupEnv(cfg.Go.NetrcEnv)
+		if !ok {
+			return nil, fmt.Errorf("the environment variable '%s' set as 'netrc-env' in saddle.toml is not set", cfg.Go.NetrcEnv)
+		}
+		private.NetrcData = data
+	}
+	if err = gotool.UsePrivate(private); err != nil {
+		_ = gotool.Close()
+		return nil, fmt.Errorf("unable to use credentials for private modules: %w", err)
+	}
+	return gotool, nil
+}
+
+// pinnedVersions returns the exact module versions pinned in the lock file, by module name.
+func pinnedVersions(lock config.LockFile) map[string]string {
+	pinned := map[string]string{}
+	if lock.Dragonfly != "" {
+		pinned["github.com/df-mc/dragonfly"] = lock.Dragonfly
+	}
+	if lock.Api != "" {
+		pinned["github.com/saddlemc/saddle"] = lock.Api
+	}
+	for module, checksum := range lock.Plugins {
+		// Only plugins that are go modules have a version, which is stored in their checksum.
+		if strings.HasPrefix(checksum, "git:") {
+			pinned[module] = strings.TrimPrefix(checksum, "git:")
+		}
+	}
+	return pinned
+}
+
+// bundleSettings returns the settings that the server should be bundled with. The path of the settings is not set.
+func bundleSettings(cfg *config.Config, pluginModules []plugin.Module) (bundler.Settings, error) {
+	// absIfLocal is a helper function used in this function. It makes the path absolute if not empty.
+	absIfLocal := func(s string) (string, error) {
+		if s == "" {
+			return s, nil
+		}
+		return filepath.Abs(s)
+	}
+
+	dfReplace, err := absIfLocal(cfg.Server.DragonflyReplace)
+	if err != nil {
+		return bundler.Settings{}, fmt.Errorf("unable to get current working directory: %w", err)
+	}
+	apiReplace, err := absIfLocal(cfg.Server.ApiReplace)
+	if err != nil {
+		return bundler.Settings{}, fmt.Errorf("unable to get current working directory: %w", err)
+	}
+	// Insert dragonfly and saddle into the bundler configuration.
+	var (
+		modules = append(make([]bundler.Module, 0, len(pluginModules)+2),
+			bundler.Module{
+				Name:    "github.com/df-mc/dragonfly",
+				Version: cfg.Server.Dragonfly,
+				Replace: dfReplace,
+			},
+			bundler.Module{
+				Name:    "github.com/saddlemc/saddle",
+				Version: cfg.Server.Api,
+				Replace: apiReplace,
+			},
+		)
+		imports = append(make([]bundler.Import, 0, len(pluginModules)+1),
+			bundler.Import{
+				Package: "github.com/saddlemc/saddle",
+				Alias:   ".",
+			},
+		)
+	)
+	// Convert all the plugin information to information that the bundler accepts.
+	for _, pl := range pluginModules {
+		modules = append(modules, bundler.Module{
+			Name:    pl.Module,
+			Version: pl.Version,
+			Replace: pl.Replace,
+		})
+		imports = append(imports, bundler.Import{
+			Package: pl.Import,
+			Alias:   "_",
+		})
+	}
+	return bundler.Settings{
+		Modules:   modules,
+		Imports:   imports,
+		Run:       "Run()",
+		GoVersion: cfg.Go.Version,
+	}, nil
+}
+
+// buildInputs returns all inputs of the build that are not already part of the bundler settings. These are used to
+// compute the build fingerprint.
+func buildInputs(gotool *toolchain.Go, cfg *config.Config, lock config.LockFile) (map[string]string, error) {
+	inputs := map[string]string{}
+
+	// The toolchain version, the target platform and any build flags set through the environment all affect the
+	// resulting binary.
+	env, err := gotool.Env("GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED")
+	if err != nil {
+		return nil, fmt.Errorf("error reading go environment: %w", err)
+	}
+	for k, v := range env {
+		inputs["env:"+k] = v
+	}
+	// The -mod flag only controls whether go.mod may be updated, which does not affect the binary. It is removed so
+	// that offline mode, which sets this flag, does not cause a rebuild.
+	var goflags []string
+	for _, f := range strings.Fields(env["GOFLAGS"]) {
+		if !strings.HasPrefix(f, "-mod=") {
+			goflags = append(goflags, f)
+		}
+	}
+	inputs["env:GOFLAGS"] = strings.Join(goflags, " ")
+
+	// The server configuration and the plugin checksums are included too, so that changes which are not visible in
+	// the generated files, such as a new commit for a plugin on a branch, still trigger a rebuild.
+	serverCfg, err := json.Marshal(cfg.Server)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding server configuration: %w", err)
+	}
+	inputs["config:server"] = string(serverCfg)
+	for module, checksum := range lock.Plugins {
+		inputs["plugin:"+module] = checksum
+	}
+	return inputs, nil
+}
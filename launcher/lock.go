@@ -0,0 +1,137 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/plugin"
+	"github.com/saddlemc/launcher/plugin/provider"
+	"sort"
+)
+
+// ErrNoConflicts is returned by MergeLock if the lock file does not contain any merge conflicts.
+var ErrNoConflicts = errors.New("the lock file has no merge conflicts")
+
+// MergeResult is the result of merging a lock file with merge conflicts.
+type MergeResult struct {
+	// Lock is the merged lock file.
+	Lock config.LockFile
+	// Resolved describes how each conflicting entry was resolved.
+	Resolved []string
+	// Rebuild is true if the fingerprint was cleared because it conflicted, so that the server is rebuilt the next time
+	// it is started.
+	Rebuild bool
+}
+
+// MergeLock merges a lock file that contains git merge conflicts. Entries that are the same on both sides, or that are
+// only present on one side, are kept. Only the conflicting entries are resolved again using the configuration. The
+// fingerprint is cleared if it conflicts, so that the server is rebuilt the next time it is started.
+func MergeLock(ctx context.Context, opts Options, data []byte) (MergeResult, error) {
+	oursData, theirsData, conflicted := config.SplitConflicts(data)
+	if !conflicted {
+		return MergeResult{}, ErrNoConflicts
+	}
+	ours, err := config.ParseLock(oursData)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("error parsing current side of the lock file: %w", err)
+	}
+	theirs, err := config.ParseLock(theirsData)
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("error parsing incoming side of the lock file: %w", err)
+	}
+
+	res := MergeResult{Lock: ours}
+	res.Lock.Plugins = map[string]string{}
+	conflicts := map[string]bool{}
+	for m, checksum := range ours.Plugins {
+		res.Lock.Plugins[m] = checksum
+	}
+	for m, checksum := range theirs.Plugins {
+		if x, ok := res.Lock.Plugins[m]; ok && x != checksum {
+			conflicts[m] = true
+			continue
+		}
+		res.Lock.Plugins[m] = checksum
+	}
+	if ours.Fingerprint != theirs.Fingerprint {
+		res.Lock.Fingerprint = ""
+		res.Rebuild = ours.Fingerprint != ""
+	}
+	if ours.Api == theirs.Api && ours.Dragonfly == theirs.Dragonfly && len(conflicts) == 0 {
+		return res, nil
+	}
+
+	gotool, err := FindGo(opts.Config)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	defer gotool.Close()
+	if opts.Offline {
+		gotool.UseOffline()
+	}
+	popts := provider.Options{Go: gotool, Offline: opts.Offline}
+	if opts.Offline {
+		// Conflicting versions are resolved again when online. Offline, they resolve to our pinned versions instead.
+		popts.Pinned = pinnedVersions(ours)
+	}
+
+	cfg := opts.Config
+	for _, m := range []struct {
+		name, version, replace string
+		ours, theirs           string
+		dst                    *string
+	}{
+		{"github.com/df-mc/dragonfly", cfg.Server.Dragonfly, cfg.Server.DragonflyReplace, ours.Dragonfly, theirs.Dragonfly, &res.Lock.Dragonfly},
+		{"github.com/saddlemc/saddle", cfg.Server.Api, cfg.Server.ApiReplace, ours.Api, theirs.Api, &res.Lock.Api},
+	} {
+		if m.ours == m.theirs {
+			continue
+		}
+		// Replaced modules are not resolved, like when the server is built.
+		version := m.version
+		if m.replace == "" {
+			if version, err = provider.ResolveModule(ctx, popts, m.name, m.version); err != nil {
+				return MergeResult{}, fmt.Errorf("error resolving %s: %w", m.name, err)
+			}
+		}
+		*m.dst = version
+		res.Resolved = append(res.Resolved, fmt.Sprintf("%s: %s / %s -> %s", m.name, m.ours, m.theirs, version))
+	}
+
+	if len(conflicts) > 0 {
+		plugins, err := plugin.Parse(cfg.Plugin, provider.Providers(popts))
+		if err != nil {
+			return MergeResult{}, fmt.Errorf("error parsing plugins: %w", err)
+		}
+		for num, pl := range plugins {
+			// Entries for modules are only resolved if their module conflicts. Other entries are resolved, as the
+			// module that they provide is not known until they are.
+			if m, ok := cfg.Plugin[num]["module"].(string); ok && !conflicts[m] {
+				continue
+			}
+			latest, err := pl.Latest(ctx, opts.progress(num, cfg.Plugin[num]))
+			if err != nil {
+				return MergeResult{}, fmt.Errorf("error fetching latest version for plugin entry #%d: %w", num+1, err)
+			}
+			if !conflicts[latest.Module] {
+				continue
+			}
+			delete(conflicts, latest.Module)
+			res.Resolved = append(res.Resolved, fmt.Sprintf("%s: %s / %s -> %s", latest.Module,
+				ours.Plugins[latest.Module], theirs.Plugins[latest.Module], latest.Checksum))
+			res.Lock.Plugins[latest.Module] = latest.Checksum
+		}
+		// Any conflicting plugin that is left is no longer in the configuration.
+		left := make([]string, 0, len(conflicts))
+		for m := range conflicts {
+			left = append(left, m)
+		}
+		sort.Strings(left)
+		for _, m := range left {
+			delete(res.Lock.Plugins, m)
+			res.Resolved = append(res.Resolved, fmt.Sprintf("%s: removed, it is no longer in the configuration", m))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package launcher
+
+import (
+	"fmt"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/logs"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// OutputLogName is the name of the file in the output directory that the output of the server is written to.
+	OutputLogName = "server.log"
+	// CrashLogName is the name of the file in the output directory that holds the output of the last run that crashed.
+	CrashLogName = "server-crash.log"
+)
+
+// OutputLog stores the output of the server in log files, which are rotated as configured.
+type OutputLog struct {
+	dir  string
+	file *logs.File
+	// tail keeps the last lines of output of the current run, which may have been rotated out of the log file already.
+	tail *logs.Tail
+}
+
+// OpenOutputLog opens the log file that the output of the server is written to. If the output should not be logged
+// according to the configuration, nil is returned. The file is rotated when it is opened, so that the output of every
+// run starts in a new file.
+func OpenOutputLog(cfg *config.Config) (*OutputLog, error) {
+	if cfg.Output.Dir == "" {
+		return nil, nil
+	}
+	file, err := logs.Open(filepath.Join(cfg.Output.Dir, OutputLogName), logs.Rotation{
+		MaxSize:  cfg.Output.MaxSize << 20,
+		Interval: time.Duration(cfg.Output.RotateInterval) * time.Hour,
+		MaxFiles: cfg.Output.MaxFiles,
+		MaxAge:   time.Duration(cfg.Output.MaxAge) * time.Hour * 24,
+		Compress: cfg.Output.Compress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Rotate(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &OutputLog{dir: cfg.Output.Dir, file: file, tail: logs.NewTail(CrashOutputLines)}, nil
+}
+
+// Rotate rotates the log file, so that the output of the next run starts in a new file.
+func (o *OutputLog) Rotate() error {
+	o.tail.Reset()
+	return o.file.Rotate()
+}
+
+// Tee returns writers for the stdout and stderr of the server, which write to both the writers passed and the log
+// file.
+func (o *OutputLog) Tee(stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	return io.MultiWriter(stdout, o.file, o.tail), io.MultiWriter(stderr, o.file, o.tail)
+}
+
+// Crashed stores the output of the current run as the crash log, replacing the crash log of any previous crash, and
+// returns its path. If the log file was rotated during the run, the last CrashOutputLines lines of output are stored
+// instead when the current log file holds fewer. The output continues to be written to the current log file.
+func (o *OutputLog) Crashed() (string, error) {
+	path := filepath.Join(o.dir, CrashLogName)
+	data, err := os.ReadFile(o.file.Path())
+	if err != nil {
+		return "", fmt.Errorf("error reading server output: %w", err)
+	}
+	// Both hold the end of the output of the run, so the longer one holds the most of it.
+	if tail := o.tail.Bytes(); len(tail) > len(data) {
+		data = tail
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("error writing crash log: %w", err)
+	}
+	return path, nil
+}
+
+// Close closes the log file.
+func (o *OutputLog) Close() error {
+	return o.file.Close()
+}
@@ -0,0 +1,84 @@
+package launcher
+
+import (
+	"github.com/saddlemc/launcher/config"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOutputLogCrashed(t *testing.T) {
+	tests := []struct {
+		name string
+		// maxSize is the size in megabytes after which the log file is rotated.
+		maxSize int64
+		// runs holds the writes of each run of the server. The last run is the one that crashed.
+		runs [][]string
+		want string
+	}{
+		{
+			name: "single run",
+			runs: [][]string{{"starting\n", "panic: oops\n"}},
+			want: "starting\npanic: oops\n",
+		},
+		{
+			name: "previous run excluded",
+			runs: [][]string{{"first run\n"}, {"second run\n", "panic: oops\n"}},
+			want: "second run\npanic: oops\n",
+		},
+		{
+			name:    "rotated during run",
+			maxSize: 1,
+			// The first write fills the log file up to just below its maximum size, so that it is rotated right before
+			// the panic is written.
+			runs: [][]string{{strings.Repeat("line\n", (1<<20)/5), "panic: oops\n"}},
+			want: strings.Repeat("line\n", CrashOutputLines-1) + "panic: oops\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Output.Dir = t.TempDir()
+			cfg.Output.MaxSize = test.maxSize
+			out, err := OpenOutputLog(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+			for i, run := range test.runs {
+				if i > 0 {
+					if err := out.Rotate(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				stdout, _ := out.Tee(io.Discard, io.Discard)
+				for _, w := range run {
+					if _, err := stdout.Write([]byte(w)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			path, err := out.Crashed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != test.want {
+				t.Errorf("expected crash log of %d bytes ending in %q, got %d bytes ending in %q", len(test.want),
+					last(test.want, 40), len(got), last(got, 40))
+			}
+		})
+	}
+}
+
+// last returns the last n bytes of s.
+func last(s string, n int) string {
+	if len(s) > n {
+		return s[len(s)-n:]
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package launcher
+
+import (
+	"fmt"
+	"github.com/saddlemc/launcher/bundler"
+	"strconv"
+	"strings"
+)
+
+// Frame is a single frame of a goroutine in a panic trace.
+type Frame struct {
+	// Function is the full name of the function, such as "example.com/plugin.(*Handler).HandleChat".
+	Function string
+	// Package is the import path of the package the function is in, such as "example.com/plugin".
+	Package string
+	// File and Line are the position in the source code that the frame is at.
+	File string
+	Line int
+}
+
+// String returns the position of the frame as "file:line".
+func (f Frame) String() string {
+	return fmt.Sprintf("%s:%d", f.File, f.Line)
+}
+
+// ParseFrames parses the frames of the first goroutine in a panic trace, as returned by PanicTrace. The goroutine that
+// created it, if any, is included as the last frame.
+func ParseFrames(trace string) []Frame {
+	lines := strings.Split(strings.ReplaceAll(trace, "\r\n", "\n"), "\n")
+	var frames []Frame
+	inGoroutine := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "goroutine ") {
+			if inGoroutine {
+				// Only the first goroutine is the one that panicked.
+				break
+			}
+			inGoroutine = true
+			continue
+		}
+		if !inGoroutine || line == "" || strings.HasPrefix(line, "\t") || i+1 >= len(lines) ||
+			!strings.HasPrefix(lines[i+1], "\t") {
+			continue
+		}
+		fn := strings.TrimPrefix(line, "created by ")
+		if j := strings.Index(fn, " in goroutine "); j >= 0 {
+			fn = fn[:j]
+		}
+		if strings.HasSuffix(fn, ")") {
+			// Strip the arguments of the function, such as "(0xc000012345, 0x1)".
+			if j := strings.LastIndex(fn, "("); j > 0 {
+				fn = fn[:j]
+			}
+		}
+		file, lineNum, ok := parsePosition(lines[i+1])
+		if !ok {
+			continue
+		}
+		frames = append(frames, Frame{Function: fn, Package: packageOf(fn), File: file, Line: lineNum})
+		i++
+	}
+	return frames
+}
+
+// parsePosition parses the position line of a frame, such as "\t/path/to/file.go:12 +0x1d".
+func parsePosition(line string) (string, int, bool) {
+	line = strings.TrimSpace(line)
+	if i := strings.LastIndex(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(line[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return line[:i], n, true
+}
+
+// packageOf returns the import path of the package of a function name. The package name ends at the first dot after the
+// last slash, as the last element of a path may not contain dots in traces.
+func packageOf(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
+
+// Attribution describes the bundled module that a panic originated in.
+type Attribution struct {
+	// Module is the module that the panic originated in.
+	Module bundler.Module
+	// Frame is the topmost frame of the panicking goroutine in the module.
+	Frame Frame
+	// Plugin is true if the module is a plugin, and false if it is dragonfly or the Saddle API.
+	Plugin bool
+}
+
+// String describes the attribution, such as "plugin example.com/plugin (/path/to/file.go:12)".
+func (a Attribution) String() string {
+	if a.Plugin {
+		return fmt.Sprintf("plugin %s (%s)", a.Module.Name, a.Frame)
+	}
+	return fmt.Sprintf("%s (%s)", a.Module.Name, a.Frame)
+}
+
+// Repository returns the URL of the repository of the module, where issues can be reported. It is derived from the
+// path of the module, so it is also returned for modules that are replaced by a local directory.
+func (a Attribution) Repository() string {
+	parts := strings.Split(a.Module.Name, "/")
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org", "codeberg.org":
+		if len(parts) >= 3 {
+			return "https://" + strings.Join(parts[:3], "/")
+		}
+	}
+	return "https://" + a.Module.Name
+}
+
+// AttributePanic finds the bundled module that a panic trace originated in. The topmost frame in a plugin is preferred,
+// as a panic in dragonfly or the Saddle API is usually caused by the plugin calling it. If no plugin is found in the
+// trace, the topmost frame in dragonfly or the Saddle API is used. False is returned if no frame is in any of the
+// modules.
+func AttributePanic(trace string, modules []bundler.Module) (Attribution, bool) {
+	var server *Attribution
+	for _, f := range ParseFrames(trace) {
+		m, ok := moduleOf(f.Package, modules)
+		if !ok {
+			continue
+		}
+		a := Attribution{Module: m, Frame: f, Plugin: m.Name != "github.com/df-mc/dragonfly" && m.Name != "github.com/saddlemc/saddle"}
+		if a.Plugin {
+			return a, true
+		}
+		if server == nil {
+			server = &a
+		}
+	}
+	if server == nil {
+		return Attribution{}, false
+	}
+	return *server, true
+}
+
+// moduleOf returns the module that provides a package. If multiple modules match, such as nested modules, the one with
+// the longest path is returned.
+func moduleOf(pkg string, modules []bundler.Module) (bundler.Module, bool) {
+	var found bundler.Module
+	for _, m := range modules {
+		if (pkg == m.Name || strings.HasPrefix(pkg, m.Name+"/")) && len(m.Name) > len(found.Name) {
+			found = m
+		}
+	}
+	return found, found.Name != ""
+}
@@ -0,0 +1,217 @@
+package launcher
+
+import (
+	"github.com/saddlemc/launcher/bundler"
+	"reflect"
+	"testing"
+)
+
+// Panic traces as written by the Go runtime, with the plugin example.com/plugin calling into dragonfly.
+const (
+	// pluginTrace is a panic in a plugin, called by dragonfly.
+	pluginTrace = `panic: assignment to entry in nil map
+
+goroutine 1 [running]:
+example.com/plugin.(*Handler).HandleChat.func1(...)
+	/home/user/plugin/handler.go:11
+example.com/plugin.apply[...](...)
+	/home/user/plugin/handler.go:16
+example.com/plugin.(*Handler).HandleChat(0x3a7984cfe040, 0x529b48?)
+	/home/user/plugin/handler.go:11 +0x54
+github.com/df-mc/dragonfly/server/player.(*Player).Chat(...)
+	/root/go/pkg/mod/github.com/df-mc/dragonfly@v0.9.9/server/player/player.go:10
+main.main()
+	/srv/saddle/.saddle/build/main.go:27 +0x139
+exit status 2
+`
+	// goroutineTrace is a panic in a goroutine started by the plugin, followed by the other goroutines.
+	goroutineTrace = `panic: assignment to entry in nil map [recovered]
+	panic: assignment to entry in nil map
+
+goroutine 6 [running]:
+panic({0x4a1b20?, 0x4d6f50?})
+	/usr/local/go/src/runtime/panic.go:770 +0x132
+github.com/df-mc/dragonfly/server/player.(*Player).Chat(...)
+	/srv/saddle/vendor/github.com/df-mc/dragonfly/server/player/player.go:10
+example.com/plugin.(*Loader).Load.func1()
+	/home/user/plugin/loader.go:24 +0x62
+created by example.com/plugin.(*Loader).Load in goroutine 1
+	/home/user/plugin/loader.go:24 +0xed
+
+goroutine 1 [chan receive]:
+main.main()
+	/srv/saddle/.saddle/build/main.go:6 +0x39
+`
+	// dragonflyTrace is a panic in dragonfly, vendored in the build, without any frames of a plugin.
+	dragonflyTrace = `panic: runtime error: index out of range [3] with length 3
+
+goroutine 12 [running]:
+github.com/df-mc/dragonfly/server/world.(*Column).Block(...)
+	/srv/saddle/vendor/github.com/df-mc/dragonfly/server/world/column.go:42
+github.com/df-mc/dragonfly/server/world.(*World).Block(0xc000120000, {0x10, 0x40, 0x3})
+	/srv/saddle/vendor/github.com/df-mc/dragonfly/server/world/world.go:120 +0x1a5
+github.com/saddlemc/saddle/event.(*Bus).Dispatch(0xc0001a2000)
+	/srv/saddle/vendor/github.com/saddlemc/saddle/event/bus.go:31 +0x45
+created by github.com/df-mc/dragonfly/server/world.(*World).tick in goroutine 9
+	/srv/saddle/vendor/github.com/df-mc/dragonfly/server/world/world.go:88 +0x7b
+`
+	// runtimeTrace is a fatal error without any frames in a bundled module.
+	runtimeTrace = `fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [chan receive]:
+main.main()
+	/srv/saddle/.saddle/build/main.go:6 +0x39
+
+goroutine 5 [select (no cases)]:
+example.com/plugin.(*Loader).Load.func1()
+	/home/user/plugin/loader.go:5 +0xf
+created by example.com/plugin.(*Loader).Load in goroutine 1
+	/home/user/plugin/loader.go:5 +0x2d
+`
+)
+
+func TestParseFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []Frame
+	}{
+		{
+			name:  "plugin",
+			trace: pluginTrace,
+			want: []Frame{
+				{"example.com/plugin.(*Handler).HandleChat.func1", "example.com/plugin", "/home/user/plugin/handler.go", 11},
+				{"example.com/plugin.apply[...]", "example.com/plugin", "/home/user/plugin/handler.go", 16},
+				{"example.com/plugin.(*Handler).HandleChat", "example.com/plugin", "/home/user/plugin/handler.go", 11},
+				{"github.com/df-mc/dragonfly/server/player.(*Player).Chat", "github.com/df-mc/dragonfly/server/player", "/root/go/pkg/mod/github.com/df-mc/dragonfly@v0.9.9/server/player/player.go", 10},
+				{"main.main", "main", "/srv/saddle/.saddle/build/main.go", 27},
+			},
+		},
+		{
+			name:  "goroutine",
+			trace: goroutineTrace,
+			want: []Frame{
+				{"panic", "panic", "/usr/local/go/src/runtime/panic.go", 770},
+				{"github.com/df-mc/dragonfly/server/player.(*Player).Chat", "github.com/df-mc/dragonfly/server/player", "/srv/saddle/vendor/github.com/df-mc/dragonfly/server/player/player.go", 10},
+				{"example.com/plugin.(*Loader).Load.func1", "example.com/plugin", "/home/user/plugin/loader.go", 24},
+				{"example.com/plugin.(*Loader).Load", "example.com/plugin", "/home/user/plugin/loader.go", 24},
+			},
+		},
+		{
+			name:  "no module frames",
+			trace: runtimeTrace,
+			want:  []Frame{{"main.main", "main", "/srv/saddle/.saddle/build/main.go", 6}},
+		},
+		{
+			name:  "windows line endings",
+			trace: "panic: oops\r\n\r\ngoroutine 1 [running]:\r\nexample.com/plugin.Load()\r\n\tC:/plugin/load.go:3 +0x25\r\n",
+			want:  []Frame{{"example.com/plugin.Load", "example.com/plugin", "C:/plugin/load.go", 3}},
+		},
+		{
+			name:  "no goroutine",
+			trace: "panic: oops\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseFrames(test.trace); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected frames %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		line string
+		file string
+		n    int
+		ok   bool
+	}{
+		{line: "\t/home/user/plugin/handler.go:11 +0x54", file: "/home/user/plugin/handler.go", n: 11, ok: true},
+		{line: "\t/home/user/plugin/handler.go:16", file: "/home/user/plugin/handler.go", n: 16, ok: true},
+		{line: "\tC:/Users/user/plugin/handler.go:7 +0x1d", file: "C:/Users/user/plugin/handler.go", n: 7, ok: true},
+		{line: "\t/root/go/pkg/mod/example.com/plugin@v1.0.0-20240101000000-abcdef123456/a.go:3", file: "/root/go/pkg/mod/example.com/plugin@v1.0.0-20240101000000-abcdef123456/a.go", n: 3, ok: true},
+		{line: "\t/home/user/plugin/handler.go +0x54"},
+		{line: "\t/home/user/plugin/handler.go:x"},
+		{line: ""},
+	}
+	for _, test := range tests {
+		file, n, ok := parsePosition(test.line)
+		if file != test.file || n != test.n || ok != test.ok {
+			t.Errorf("parsePosition(%q): expected (%q, %d, %v), got (%q, %d, %v)", test.line, test.file, test.n, test.ok, file, n, ok)
+		}
+	}
+}
+
+func TestPackageOf(t *testing.T) {
+	tests := []struct {
+		fn, want string
+	}{
+		{fn: "main.main", want: "main"},
+		{fn: "panic", want: "panic"},
+		{fn: "runtime.gopark", want: "runtime"},
+		{fn: "example.com/plugin.(*Handler).HandleChat.func1", want: "example.com/plugin"},
+		{fn: "example.com/plugin.apply[...]", want: "example.com/plugin"},
+		{fn: "github.com/df-mc/dragonfly/server/player.(*Player).Chat", want: "github.com/df-mc/dragonfly/server/player"},
+		{fn: "gopkg.in/yaml%2ev3.Unmarshal", want: "gopkg.in/yaml%2ev3"},
+	}
+	for _, test := range tests {
+		if got := packageOf(test.fn); got != test.want {
+			t.Errorf("packageOf(%q): expected %q, got %q", test.fn, test.want, got)
+		}
+	}
+}
+
+func TestAttributePanic(t *testing.T) {
+	modules := []bundler.Module{
+		{Name: "github.com/df-mc/dragonfly", Version: "v0.9.9"},
+		{Name: "github.com/saddlemc/saddle", Version: "v0.1.0"},
+		{Name: "example.com/plugin", Version: "v1.0.0"},
+	}
+	tests := []struct {
+		name   string
+		trace  string
+		module string
+		frame  string
+		plugin bool
+	}{
+		{name: "plugin", trace: pluginTrace, module: "example.com/plugin", frame: "/home/user/plugin/handler.go:11", plugin: true},
+		{name: "plugin below dragonfly", trace: goroutineTrace, module: "example.com/plugin", frame: "/home/user/plugin/loader.go:24", plugin: true},
+		{name: "vendored dragonfly", trace: dragonflyTrace, module: "github.com/df-mc/dragonfly", frame: "/srv/saddle/vendor/github.com/df-mc/dragonfly/server/world/column.go:42"},
+		{name: "no module frames", trace: runtimeTrace},
+		{name: "empty", trace: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, ok := AttributePanic(test.trace, modules)
+			if ok != (test.module != "") {
+				t.Fatalf("expected attribution: %v, got %v (%v)", test.module != "", ok, a)
+			}
+			if !ok {
+				return
+			}
+			if a.Module.Name != test.module || a.Frame.String() != test.frame || a.Plugin != test.plugin {
+				t.Errorf("expected %s at %s (plugin: %v), got %s at %s (plugin: %v)", test.module, test.frame,
+					test.plugin, a.Module.Name, a.Frame, a.Plugin)
+			}
+		})
+	}
+}
+
+func TestAttributionRepository(t *testing.T) {
+	tests := []struct {
+		module, want string
+	}{
+		{module: "github.com/df-mc/dragonfly", want: "https://github.com/df-mc/dragonfly"},
+		{module: "github.com/author/plugins/chat", want: "https://github.com/author/plugins"},
+		{module: "gitlab.com/author/plugin", want: "https://gitlab.com/author/plugin"},
+		{module: "example.com/plugin", want: "https://example.com/plugin"},
+	}
+	for _, test := range tests {
+		a := Attribution{Module: bundler.Module{Name: test.module}}
+		if got := a.Repository(); got != test.want {
+			t.Errorf("expected repository of %s to be %s, got %s", test.module, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package launcher
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/saddlemc/launcher/bundler"
+	"github.com/saddlemc/launcher/config"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// ManifestName is the name of the manifest file in a vendor directory.
+const ManifestName = "saddle-vendor.json"
+
+// Manifest describes a vendor directory. It allows the server in the directory to be built and installed without any
+// access to the configuration or network that it was created with.
+type Manifest struct {
+	// Lock is the lock file describing the vendored server.
+	Lock config.LockFile
+	// Go is the version of the Go toolchain that the directory was created with.
+	Go string
+	// Created is the time at which the directory was created.
+	Created time.Time
+	// Modules are all modules bundled with the server. Local modules are replaced with their copy in the directory.
+	Modules []bundler.Module
+}
+
+var (
+	//go:embed export.templ
+	exportTemplateString string
+	exportTemplate       = template.Must(template.New("README.md").Parse(exportTemplateString))
+)
+
+// Vendor bundles the server described by the plan into dir, which must not exist or be empty, together with all of
+// its dependencies and local modules. The directory can then be built using only a Go toolchain, for example on a
+// machine without network access, by BuildVendor.
+func Vendor(ctx context.Context, p Plan, dir string) error {
+	logger := p.Options.phaseLogger("build")
+	// Local modules are copied into the directory, so that the directory can be moved to another machine.
+	settings, err := writeProject(ctx, p, dir, func(dir string, m bundler.Module) (string, error) {
+		rel := path.Join("local", m.Name)
+		logger.Debug().Msgf("Copying '%s' to '%s'...", m.Replace, rel)
+		if err := copyDir(m.Replace, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return "", fmt.Errorf("could not copy local module %s: %w", m.Name, err)
+		}
+		return "./" + rel, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Debug().Msgf("Running 'go mod vendor'...")
+	cmd := p.Go.CommandContext(ctx, "mod", "vendor")
+	cmd.Dir = settings.Path
+	cmd.Stderr = p.Options.output()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not vendor server dependencies: %w", err)
+	}
+
+	data, err := json.MarshalIndent(Manifest{
+		Lock:    p.Lock,
+		Go:      p.Go.Version,
+		Created: time.Now().UTC(),
+		Modules: settings.Modules,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", ManifestName, err)
+	}
+	if err := os.WriteFile(filepath.Join(settings.Path, ManifestName), data, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %w", ManifestName, err)
+	}
+	return nil
+}
+
+// BuildVendor builds and installs the server from a directory created by Vendor, without using the network. Only the
+// server path, toolchain, build cache and build history settings of the options' configuration are used.
+func BuildVendor(ctx context.Context, opts Options, dir string) (Result, error) {
+	logger, cfg := opts.phaseLogger("build"), opts.Config
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return Result{}, fmt.Errorf("could not read %s, is '%s' a vendor directory? %w", ManifestName, dir, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Result{}, fmt.Errorf("could not parse %s: %w", ManifestName, err)
+	}
+
+	binary, err := ServerPath(cfg)
+	if err != nil {
+		return Result{}, err
+	}
+	gotool, err := FindGo(cfg)
+	if err != nil {
+		return Result{}, err
+	}
+	defer gotool.Close()
+	gotool.UseOffline()
+	if gotool.Version != manifest.Go {
+		logger.Warn().Msgf("The vendor directory was created with %s, but %s is used to build it.", manifest.Go, gotool.Version)
+	}
+
+	res := Result{Binary: binary, Lock: manifest.Lock}
+	buildStart := time.Now()
+	staged := binary + ".new"
+	if err := compile(ctx, gotool, opts.output(), dir, staged, "-mod=vendor"); err != nil {
+		return res, err
+	}
+	res.Duration = time.Since(buildStart)
+	logger.Info().Msgf("Done! Finished building in %.3f seconds.", res.Duration.Seconds())
+
+	lock, err := install(opts, OpenHistory(cfg), binary, staged, manifest.Lock)
+	if err != nil {
+		return res, err
+	}
+	res.Installed = true
+	buildCache, err := OpenCache(cfg)
+	if err != nil {
+		logger.Error().Msgf("Unable to open build cache: %v", err)
+	} else if buildCache != nil {
+		logger.Debug().Msgf("Storing server in build cache...")
+		if err := buildCache.Put(manifest.Lock.Fingerprint, binary, lock); err != nil {
+			logger.Error().Msgf("Unable to store server in build cache: %v", err)
+		}
+	}
+	return res, nil
+}
+
+// Export writes the server described by the plan to dir as a Go module, which must not exist or be empty. Unlike the
+// temporary directory used by Build, the directory is kept, so that it can be opened in an editor, debugged or built
+// by other tools. Local modules are referred to by a relative path, and a README describing the module is added.
+func Export(ctx context.Context, p Plan, dir string) error {
+	// Local modules are referred to by a relative path, so that the project keeps working if both it and the modules
+	// are moved together.
+	settings, err := writeProject(ctx, p, dir, func(dir string, m bundler.Module) (string, error) {
+		rel, err := filepath.Rel(dir, m.Replace)
+		if err != nil {
+			// This happens if the paths are on different drives, in which case only an absolute path works.
+			return m.Replace, nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ".." || len(rel) > 2 && rel[:3] == "../" {
+			return rel, nil
+		}
+		return "./" + rel, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(settings.Path, "README.md"))
+	if err != nil {
+		return fmt.Errorf("could not create README.md: %w", err)
+	}
+	defer f.Close()
+	err = exportTemplate.Execute(f, struct {
+		bundler.Settings
+		Go         string
+		Created    time.Time
+		WorkingDir string
+	}{
+		Settings:   settings,
+		Go:         p.Go.Version,
+		Created:    time.Now(),
+		WorkingDir: filepath.Dir(p.Binary),
+	})
+	if err != nil {
+		return fmt.Errorf("could not write README.md: %w", err)
+	}
+	return nil
+}
+
+// writeProject writes the server described by the plan as a Go module to dir, which must not exist or be empty.
+// Modules that are replaced with a local directory get the replacement returned by the replace function. Afterwards,
+// the dependencies of the module are resolved so that it can be built directly. The settings used to bundle the
+// server are returned.
+func writeProject(ctx context.Context, p Plan, dir string, replace func(dir string, m bundler.Module) (string, error)) (bundler.Settings, error) {
+	logger := p.Options.phaseLogger("build")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return bundler.Settings{}, fmt.Errorf("unable to get current working directory: %w", err)
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return bundler.Settings{}, fmt.Errorf("the directory '%s' already exists and is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return bundler.Settings{}, fmt.Errorf("could not create directory: %w", err)
+	}
+
+	settings := p.Settings
+	settings.Path = dir
+	settings.Modules = append([]bundler.Module(nil), settings.Modules...)
+	for i, m := range settings.Modules {
+		if m.Replace == "" {
+			continue
+		}
+		if settings.Modules[i].Replace, err = replace(dir, m); err != nil {
+			return settings, err
+		}
+	}
+
+	logger.Debug().Msgf("Bundling plugins...")
+	if err := bundler.Bundle(settings); err != nil {
+		return settings, fmt.Errorf("could not bundle plugins: %w", err)
+	}
+	logger.Debug().Msgf("Running 'go mod tidy'...")
+	cmd := p.Go.CommandContext(ctx, "mod", "tidy")
+	cmd.Dir = dir
+	cmd.Stderr = p.Options.output()
+	if err := cmd.Run(); err != nil {
+		return settings, fmt.Errorf("could not resolve server dependencies: %w", err)
+	}
+	return settings, nil
+}
+
+// copyDir recursively copies the directory at src to dst. Version control directories are skipped.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == ".hg" || d.Name() == ".svn" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,106 @@
+package launcher
+
+import (
+	"fmt"
+	"github.com/saddlemc/launcher/bundler"
+	"github.com/saddlemc/launcher/cache"
+	"github.com/saddlemc/launcher/config"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	// historyDir is the directory in which previous server binaries are kept.
+	historyDir = filepath.Join(".saddle", "history")
+	// rollbackMarker is the file that stores the fingerprint of the build that was rolled back. As long as the
+	// configuration still results in this fingerprint, the server is not rebuilt, as it would otherwise undo the
+	// rollback.
+	rollbackMarker = filepath.Join(".saddle", "rolled-back")
+)
+
+// OpenHistory opens the build history as configured.
+func OpenHistory(cfg *config.Config) *cache.History {
+	return cache.NewHistory(historyDir, cfg.Bundler.KeepBuilds)
+}
+
+// RolledBack returns true if the build with the provided fingerprint was rolled back.
+func RolledBack(fingerprint string) bool {
+	data, err := os.ReadFile(rollbackMarker)
+	return err == nil && string(data) == fingerprint
+}
+
+// Rollback restores the most recent build in the history to the server binary of the configuration, together with
+// its lock file. The build that is replaced is marked as rolled back, so that it is not rebuilt until the
+// configuration changes. If there is no previous build, cache.ErrNoHistory is returned.
+func Rollback(cfg *config.Config) (cache.Build, error) {
+	binary, err := ServerPath(cfg)
+	if err != nil {
+		return cache.Build{}, err
+	}
+	current, _, _ := config.LoadLock(LockPath)
+	b, err := OpenHistory(cfg).Restore(binary, LockPath)
+	if err != nil {
+		return b, err
+	}
+	if err := os.WriteFile(rollbackMarker, []byte(current.Fingerprint), 0644); err != nil {
+		return b, fmt.Errorf("error storing rollback, the server may be rebuilt on the next start: %w", err)
+	}
+	return b, nil
+}
+
+// Restored returns a copy of the plan that describes the server after it was rolled back to the build with the lock
+// passed. Plugins that were not part of that build are removed from the settings of the plan.
+func (p Plan) Restored(lock config.LockFile) Plan {
+	modules := make([]bundler.Module, 0, len(p.Settings.Modules))
+	for _, m := range p.Settings.Modules {
+		switch m.Name {
+		case "github.com/df-mc/dragonfly":
+			m.Version = lock.Dragonfly
+		case "github.com/saddlemc/saddle":
+			m.Version = lock.Api
+		default:
+			if _, ok := lock.Plugins[m.Name]; !ok {
+				continue
+			}
+		}
+		modules = append(modules, m)
+	}
+	p.Settings.Modules = modules
+	p.Lock, p.Previous, p.PreviousOK = lock, lock, true
+	return p
+}
+
+// LockChanges returns a human-readable description of each difference between two lock files.
+func LockChanges(from, to config.LockFile) []string {
+	var changes []string
+	if from.Api != to.Api {
+		changes = append(changes, fmt.Sprintf("saddle api: %s -> %s", from.Api, to.Api))
+	}
+	if from.Dragonfly != to.Dragonfly {
+		changes = append(changes, fmt.Sprintf("dragonfly: %s -> %s", from.Dragonfly, to.Dragonfly))
+	}
+	modules := make([]string, 0, len(from.Plugins)+len(to.Plugins))
+	for m := range from.Plugins {
+		modules = append(modules, m)
+	}
+	for m := range to.Plugins {
+		if _, ok := from.Plugins[m]; !ok {
+			modules = append(modules, m)
+		}
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		old, hadOld := from.Plugins[m]
+		checksum, hasNew := to.Plugins[m]
+		switch {
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("added plugin %s (%s)", m, checksum))
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("removed plugin %s (%s)", m, old))
+		case old != checksum:
+			changes = append(changes, fmt.Sprintf("updated plugin %s: %s -> %s", m, old, checksum))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,80 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrKilled is returned by Run if the server did not shut down in time after the context was done, and was killed.
+var ErrKilled = errors.New("server shutdown took too long, server was killed")
+
+// RunOptions configures how the server is run.
+type RunOptions struct {
+	// Stdin, Stdout and Stderr are connected to the standard input and outputs of the server. If nil, they are
+	// connected to the null device.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Dir is the working directory of the server. If empty, the directory of the binary is used.
+	Dir string
+	// ShutdownTimeout is the time the server gets to shut down after the context is done before it is killed. If zero,
+	// the server gets 10 seconds.
+	ShutdownTimeout time.Duration
+	// Started is called with the process ID of the server once it was started, if not nil.
+	Started func(pid int)
+}
+
+// Run runs the server binary until it stops. Once the context is done, the server is interrupted and given time to
+// shut down gracefully, after which it is killed and ErrKilled is returned. If the server exits with an error, an
+// *exec.ExitError is returned.
+func Run(ctx context.Context, binary string, opts RunOptions) error {
+	cmd := exec.Command(binary)
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	cmd.Dir = opts.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(binary)
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = time.Second * 10
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if opts.Started != nil {
+		opts.Started(cmd.Process.Pid)
+	}
+
+	// Wait for the program to end, and report any error that might have occurred.
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-shutdown:
+		return err
+	case <-ctx.Done():
+	}
+	// Make sure to wait for the server to close so the user can see all the output. Interrupting the server may not be
+	// supported on all platforms, but in that case the server has usually already received the interrupt from the
+	// terminal.
+	_ = cmd.Process.Signal(os.Interrupt)
+	timeout := time.NewTimer(opts.ShutdownTimeout)
+	defer timeout.Stop()
+	select {
+	case err := <-shutdown:
+		return err
+	case <-timeout.C:
+		if err := cmd.Process.Kill(); err != nil {
+			return err
+		}
+		<-shutdown
+		return ErrKilled
+	}
+}
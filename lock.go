@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+)
+
+// lockCommand manages the lock file. It accepts the 'merge' subcommand.
+func lockCommand(logger *zerolog.Logger, args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: saddle lock merge [-offline]")
+		os.Exit(2)
+	}
+	if len(args) == 0 || args[0] != "merge" {
+		usage()
+	}
+	flags := flag.NewFlagSet("lock merge", flag.ExitOnError)
+	flagOffline := flags.Bool("offline", false,
+		"If set to true, conflicting entries are resolved to versions that are already downloaded.",
+	)
+	_ = flags.Parse(args[1:])
+
+	cfg := loadConfig(logger)
+	data, err := os.ReadFile(launcher.LockPath)
+	if err != nil {
+		logger.Fatal().Msgf("Could not read %s: %v", launcher.LockPath, err)
+	}
+	logger.Info().Msgf("Resolving conflicts in %s...", launcher.LockPath)
+	res, err := launcher.MergeLock(context.Background(), launcher.Options{
+		Config:  cfg,
+		Offline: *flagOffline,
+		Logger:  logger,
+	}, data)
+	if errors.Is(err, launcher.ErrNoConflicts) {
+		logger.Info().Msgf("%s has no merge conflicts.", launcher.LockPath)
+		return
+	} else if err != nil {
+		logger.Fatal().Msgf("Could not merge %s: %v", launcher.LockPath, err)
+	}
+	for _, r := range res.Resolved {
+		logger.Info().Msgf("  %s", r)
+	}
+	if _, err = config.WriteLock(launcher.LockPath, res.Lock); err != nil {
+		logger.Fatal().Msgf("Could not write %s: %v", launcher.LockPath, err)
+	}
+	if res.Rebuild {
+		logger.Info().Msgf("Merged %s. The server will be rebuilt the next time it is started.", launcher.LockPath)
+		return
+	}
+	logger.Info().Msgf("Merged %s.", launcher.LockPath)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/logs"
+	"io"
+	"os"
+	"strings"
+)
+
+// logFlags holds the flags that configure logging. They may be passed to every command, and override the settings in
+// the [log] section of saddle.toml.
+type logFlags struct {
+	format, level, file string
+}
+
+// logging holds the logging flags that the launcher was started with.
+var logging logFlags
+
+// logOutput is the output that messages are logged to in the terminal. It is replaced while the console is used, so that
+// messages are written above the line being typed.
+var logOutput io.Writer = os.Stdout
+
+// commandOutput is the output that the go commands run by the launcher write to, such as the compiler. Like logOutput,
+// it is replaced while the console is used.
+var commandOutput io.Writer = os.Stderr
+
+// logFile is the log file that messages are written to, if any. It is shared by every logger created, so that the file
+// is only opened once.
+var logFile *logs.File
+
+// extractLogFlags removes the logging flags from the arguments, so that the commands do not need to define them. Both
+// '-log-level debug' and '--log-level=debug' are accepted. An error is returned if a flag is missing its value.
+func extractLogFlags(args []string) ([]string, logFlags, error) {
+	var (
+		lf   logFlags
+		rest []string
+	)
+	targets := map[string]*string{"log-format": &lf.format, "log-level": &lf.level, "log-file": &lf.file}
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if name == args[i] || args[i] == "--" {
+			rest = append(rest, args[i])
+			continue
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		target, ok := targets[name]
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, logFlags{}, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+	return rest, lf, nil
+}
+
+// newLogger returns a logger configured by the logging flags and, if it is not nil, the configuration.
+func newLogger(lf logFlags, cfg *config.Config) (zerolog.Logger, error) {
+	format, levelName, file := lf.format, lf.level, lf.file
+	level := zerolog.InfoLevel
+	if cfg != nil {
+		if format == "" {
+			format = cfg.Log.Format
+		}
+		if levelName == "" {
+			levelName = cfg.Log.Level
+		}
+		if file == "" {
+			file = cfg.Log.File
+		}
+		if cfg.Bundler.Debug {
+			level = zerolog.DebugLevel
+		}
+	}
+	if levelName != "" {
+		l, err := zerolog.ParseLevel(strings.ToLower(levelName))
+		if err != nil || l == zerolog.NoLevel {
+			return zerolog.Logger{}, fmt.Errorf("unknown log level '%s'", levelName)
+		}
+		level = l
+	}
+
+	var out io.Writer
+	switch format {
+	case "", "console":
+		out = zerolog.ConsoleWriter{
+			Out:           logOutput,
+			NoColor:       !colored(logOutput),
+			PartsExclude:  []string{zerolog.TimestampFieldName},
+			FieldsExclude: []string{"phase", "duration"},
+		}
+	case "json":
+		out = logOutput
+	default:
+		return zerolog.Logger{}, fmt.Errorf("unknown log format '%s', must be 'console' or 'json'", format)
+	}
+	rot := logs.Rotation{MaxSize: 10 << 20, MaxFiles: 5}
+	if cfg != nil {
+		rot = logs.Rotation{MaxSize: cfg.Log.MaxSize << 20, MaxFiles: cfg.Log.MaxFiles}
+	}
+	f, err := openLogFile(file, rot)
+	if err != nil {
+		return zerolog.Logger{}, err
+	}
+	if f != nil {
+		out = zerolog.MultiLevelWriter(out, f)
+	}
+	return zerolog.New(out).Level(level).With().Timestamp().Logger(), nil
+}
+
+// openLogFile returns the log file at the path, or nil if the path is empty. The log file that is already open is used
+// if it has the same path, and closed otherwise. Apart from that, the file is not closed, as it is used until the
+// launcher exits. Every message is written to it directly, so nothing is lost when the launcher exits.
+func openLogFile(path string, rot logs.Rotation) (*logs.File, error) {
+	if logFile != nil && logFile.Path() == path {
+		logFile.SetRotation(rot)
+		return logFile, nil
+	}
+	var f *logs.File
+	if path != "" {
+		var err error
+		if f, err = logs.Open(path, rot); err != nil {
+			return nil, err
+		}
+	}
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+	logFile = f
+	return f, nil
+}
+
+// colored returns false if the output is a file that is not a terminal, such as the log file of a launcher running in
+// the background, in which case messages are not colored.
+func colored(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return true
+	}
+	stat, err := f.Stat()
+	return err != nil || stat.Mode()&os.ModeCharDevice != 0
+}
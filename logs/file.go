@@ -0,0 +1,272 @@
+package logs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timestamp is the layout of the time added to the name of rotated files.
+const timestamp = "2006-01-02T15-04-05"
+
+// Rotation configures when a log file is rotated, and how many rotated files are kept.
+type Rotation struct {
+	// MaxSize is the size in bytes after which the file is rotated. If 0, the file is not rotated because of its size.
+	MaxSize int64
+	// Interval is the time after which the file is rotated. If 0, the file is not rotated because of its age.
+	Interval time.Duration
+	// MaxFiles is the amount of rotated files that are kept. If 0, all rotated files are kept unless they are too old.
+	MaxFiles int
+	// MaxAge is the age after which rotated files are removed. If 0, rotated files are not removed because of their age.
+	MaxAge time.Duration
+	// Compress specifies if rotated files should be compressed using gzip.
+	Compress bool
+}
+
+// File is a log file that is rotated according to its Rotation. Rotated files are stored next to the file, with the
+// time they were rotated added to their name, such as "launcher-2006-01-02T15-04-05.log". It is safe for concurrent use.
+type File struct {
+	path string
+	rot  Rotation
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	created time.Time
+}
+
+// Open opens the log file at the path, creating it and its directory if they do not exist yet. Anything written is
+// appended to the file.
+func Open(path string, rot Rotation) (*File, error) {
+	f := &File{path: path, rot: rot}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the log file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Write writes to the log file, rotating it first if it has grown too large or too old.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	tooLarge := f.rot.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.rot.MaxSize
+	tooOld := f.rot.Interval > 0 && time.Since(f.created) > f.rot.Interval
+	if tooLarge || tooOld {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// SetRotation changes when the log file is rotated, and how many rotated files are kept.
+func (f *File) SetRotation(rot Rotation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rot = rot
+}
+
+// Rotate rotates the log file, even if it does not need to be rotated yet. Empty files are not rotated.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the log file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
+
+// open opens the file at the path of the log file.
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+	f.f, f.size, f.created = file, stat.Size(), stat.ModTime()
+	if f.size == 0 {
+		f.created = time.Now()
+	}
+	return nil
+}
+
+// rotate moves the current file aside, compressing it if needed, opens a new file and removes old rotated files.
+func (f *File) rotate() error {
+	if f.size == 0 {
+		f.created = time.Now()
+		return nil
+	}
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	f.f = nil
+
+	rotated := RotatedName(f.path, time.Now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		return fmt.Errorf("error rotating log file: %w", err)
+	}
+	if f.rot.Compress {
+		// If compressing fails, the uncompressed file is kept, so that no output is lost.
+		_ = compress(rotated)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return Cleanup(f.path, f.rot)
+}
+
+// RotatedName returns the name that the log file at the path gets when it is rotated at the time passed.
+func RotatedName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(path, ext) + "-" + t.Format(timestamp) + ext
+	// Files rotated within the same second get a number added to their name.
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", strings.TrimSuffix(path, ext), t.Format(timestamp), i, ext)
+	}
+	return name
+}
+
+// Rotated returns the paths of the rotated files of the log file at the path, from new to old.
+func Rotated(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	prefix := filepath.Base(strings.TrimSuffix(path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+".gz") {
+			continue
+		}
+		// Other files may share the prefix, so only files with a timestamp after it are included.
+		stamp := strings.TrimPrefix(name, prefix)
+		if len(stamp) < len(timestamp) {
+			continue
+		}
+		if _, err := time.Parse(timestamp, stamp[:len(timestamp)]); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(filepath.Dir(path), name))
+	}
+	// Files are sorted by the time they were last written to, which is when they were rotated. Files with the same
+	// time are sorted by the time in their name, and files rotated within the same second by the number after it.
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if stat, err := os.Stat(file); err == nil {
+			modTimes[file] = stat.ModTime()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if a, b := modTimes[files[i]], modTimes[files[j]]; !a.Equal(b) {
+			return a.After(b)
+		}
+		a, b := strings.TrimPrefix(filepath.Base(files[i]), prefix), strings.TrimPrefix(filepath.Base(files[j]), prefix)
+		if a[:len(timestamp)] != b[:len(timestamp)] {
+			return a > b
+		}
+		return rotatedNumber(a[len(timestamp):]) > rotatedNumber(b[len(timestamp):])
+	})
+	return files, nil
+}
+
+// rotatedNumber returns the number added to the name of a file rotated within the same second as another, from the
+// part of its name after the time, such as ".1.log". Files without a number return 0.
+func rotatedNumber(s string) int {
+	num, _, _ := strings.Cut(strings.TrimPrefix(s, "."), ".")
+	n, _ := strconv.Atoi(num)
+	return n
+}
+
+// Cleanup removes the rotated files of the log file at the path that should no longer be kept according to the
+// rotation.
+func Cleanup(path string, rot Rotation) error {
+	files, err := Rotated(path)
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		remove := rot.MaxFiles > 0 && i >= rot.MaxFiles
+		if stat, err := os.Stat(file); err == nil && rot.MaxAge > 0 && time.Since(stat.ModTime()) > rot.MaxAge {
+			remove = true
+		}
+		if remove {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compress compresses the file at the path using gzip, replacing it with a file with '.gz' added to its name.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err == nil {
+		err = w.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
+
+// exists returns true if a file exists at the path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
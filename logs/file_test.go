@@ -0,0 +1,290 @@
+package logs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatedName(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name     string
+		file     string
+		existing []string
+		want     string
+	}{
+		{name: "with extension", file: "launcher.log", want: "launcher-2024-03-01T12-30-45.log"},
+		{name: "without extension", file: "launcher", want: "launcher-2024-03-01T12-30-45"},
+		{
+			name:     "same second",
+			file:     "launcher.log",
+			existing: []string{"launcher-2024-03-01T12-30-45.log"},
+			want:     "launcher-2024-03-01T12-30-45.1.log",
+		},
+		{
+			name:     "same second compressed",
+			file:     "launcher.log",
+			existing: []string{"launcher-2024-03-01T12-30-45.log.gz", "launcher-2024-03-01T12-30-45.1.log"},
+			want:     "launcher-2024-03-01T12-30-45.2.log",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range test.existing {
+				writeFile(t, filepath.Join(dir, name), "", at)
+			}
+			if got := RotatedName(filepath.Join(dir, test.file), at); got != filepath.Join(dir, test.want) {
+				t.Errorf("expected '%s', got '%s'", test.want, filepath.Base(got))
+			}
+		})
+	}
+}
+
+func TestRotated(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"launcher.log":                           0,
+		"launcher-2024-03-01T12-00-00.log":       3 * time.Hour,
+		"launcher-2024-03-01T13-00-00.log.gz":    2 * time.Hour,
+		"launcher-2024-03-01T14-00-00.1.log":     time.Hour,
+		"launcher-notes.log":                     time.Hour,
+		"launcher-2024-03-01T15-00-00.txt":       time.Hour,
+		"server-2024-03-01T12-00-00.log":         time.Hour,
+		"launcher-debug-2024-03-01T12-00-00.log": time.Hour,
+	} {
+		writeFile(t, filepath.Join(dir, name), "", now.Add(-age))
+	}
+	// Files rotated within the same second may have the same modification time, in which case the number added to
+	// their name decides their order.
+	for _, name := range []string{"launcher-2024-03-01T16-00-00.log", "launcher-2024-03-01T16-00-00.1.log", "launcher-2024-03-01T16-00-00.2.log"} {
+		writeFile(t, filepath.Join(dir, name), "", now)
+	}
+	files, err := Rotated(filepath.Join(dir, "launcher.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"launcher-2024-03-01T16-00-00.2.log",
+		"launcher-2024-03-01T16-00-00.1.log",
+		"launcher-2024-03-01T16-00-00.log",
+		"launcher-2024-03-01T14-00-00.1.log",
+		"launcher-2024-03-01T13-00-00.log.gz",
+		"launcher-2024-03-01T12-00-00.log",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("expected %v, got %v", want, files)
+	}
+	for i, file := range files {
+		if file != filepath.Join(dir, want[i]) {
+			t.Errorf("expected '%s' at position %d, got '%s'", want[i], i, filepath.Base(file))
+		}
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	tests := []struct {
+		name string
+		rot  Rotation
+		want []string
+	}{
+		{
+			name: "keep all",
+			want: []string{"launcher-2024-03-01T14-00-00.log", "launcher-2024-03-01T13-00-00.log", "launcher-2024-03-01T12-00-00.log.gz"},
+		},
+		{
+			name: "max files",
+			rot:  Rotation{MaxFiles: 2},
+			want: []string{"launcher-2024-03-01T14-00-00.log", "launcher-2024-03-01T13-00-00.log"},
+		},
+		{
+			name: "max age",
+			rot:  Rotation{MaxAge: 90 * time.Minute},
+			want: []string{"launcher-2024-03-01T14-00-00.log"},
+		},
+		{
+			name: "max files and max age",
+			rot:  Rotation{MaxFiles: 1, MaxAge: 150 * time.Minute},
+			want: []string{"launcher-2024-03-01T14-00-00.log"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			writeFile(t, filepath.Join(dir, "launcher.log"), "", now)
+			writeFile(t, filepath.Join(dir, "launcher-2024-03-01T14-00-00.log"), "", now.Add(-time.Hour))
+			writeFile(t, filepath.Join(dir, "launcher-2024-03-01T13-00-00.log"), "", now.Add(-2*time.Hour))
+			writeFile(t, filepath.Join(dir, "launcher-2024-03-01T12-00-00.log.gz"), "", now.Add(-3*time.Hour))
+
+			path := filepath.Join(dir, "launcher.log")
+			if err := Cleanup(path, test.rot); err != nil {
+				t.Fatal(err)
+			}
+			files, err := Rotated(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(test.want) {
+				t.Fatalf("expected %v to be kept, got %v", test.want, files)
+			}
+			for i, file := range files {
+				if file != filepath.Join(dir, test.want[i]) {
+					t.Errorf("expected '%s' to be kept, got '%s'", test.want[i], filepath.Base(file))
+				}
+			}
+			if !exists(path) {
+				t.Errorf("expected the log file itself to be kept")
+			}
+		})
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		rot    Rotation
+		writes []string
+		// current is the content of the log file after writing, and rotated the content of the rotated files, from new
+		// to old.
+		current string
+		rotated []string
+	}{
+		{
+			name:    "not rotated",
+			rot:     Rotation{MaxSize: 100},
+			writes:  []string{"first\n", "second\n"},
+			current: "first\nsecond\n",
+		},
+		{
+			name:    "rotated by size",
+			rot:     Rotation{MaxSize: 10},
+			writes:  []string{"first\n", "second\n", "third\n"},
+			current: "third\n",
+			rotated: []string{"second\n", "first\n"},
+		},
+		{
+			name:    "larger than max size",
+			rot:     Rotation{MaxSize: 4},
+			writes:  []string{"first\n", "second\n"},
+			current: "second\n",
+			rotated: []string{"first\n"},
+		},
+		{
+			name:    "max files",
+			rot:     Rotation{MaxSize: 4, MaxFiles: 1},
+			writes:  []string{"first\n", "second\n", "third\n"},
+			current: "third\n",
+			rotated: []string{"second\n"},
+		},
+		{
+			name:    "compressed",
+			rot:     Rotation{MaxSize: 10, Compress: true},
+			writes:  []string{"first\n", "second\n"},
+			current: "second\n",
+			rotated: []string{"first\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "launcher.log")
+			f, err := Open(path, test.rot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			for _, w := range test.writes {
+				if _, err := f.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := readFile(t, path); got != test.current {
+				t.Errorf("expected log file to hold %q, got %q", test.current, got)
+			}
+			files, err := Rotated(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(test.rotated) {
+				t.Fatalf("expected %d rotated file(s), got %v", len(test.rotated), files)
+			}
+			for i, file := range files {
+				if test.rot.Compress != (filepath.Ext(file) == ".gz") {
+					t.Errorf("expected compressed: %v, got '%s'", test.rot.Compress, filepath.Base(file))
+				}
+				if got := readFile(t, file); got != test.rotated[i] {
+					t.Errorf("expected rotated file '%s' to hold %q, got %q", filepath.Base(file), test.rotated[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestFileRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "launcher.log")
+	f, err := Open(path, Rotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Empty files are not rotated.
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := Rotated(path); len(files) != 0 {
+		t.Fatalf("expected an empty file not to be rotated, got %v", files)
+	}
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := Rotated(path)
+	if len(files) != 1 || readFile(t, files[0]) != "first\n" || readFile(t, path) != "" {
+		t.Errorf("expected the file to be rotated, got %v", files)
+	}
+	_ = f.Close()
+	if _, err := f.Write([]byte("second\n")); err != os.ErrClosed {
+		t.Errorf("expected os.ErrClosed after closing, got %v", err)
+	}
+}
+
+// writeFile writes the data to the file at the path, setting its modification time.
+func writeFile(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the content of the file at the path, decompressing it if it is compressed.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,86 @@
+package logs
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MaxLineLength is the maximum length of a line kept by a Tail. Of longer lines, only the end is kept, so that output
+// that never ends a line does not grow the tail without bound.
+const MaxLineLength = 64 << 10
+
+// Tail is an io.Writer that keeps the last lines written to it in memory. It is safe for concurrent use.
+type Tail struct {
+	max int
+
+	mu      sync.Mutex
+	lines   [][]byte
+	partial []byte
+}
+
+// NewTail returns a Tail that keeps the last max lines written to it.
+func NewTail(max int) *Tail {
+	return &Tail{max: max}
+}
+
+// Write adds the data to the tail, removing the oldest lines if there are more than the maximum.
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data := append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		t.lines = append(t.lines, lineEnd(data[:i+1]))
+		data = data[i+1:]
+	}
+	t.partial = lineEnd(data)
+	if len(t.lines) > t.max {
+		t.lines = append([][]byte(nil), t.lines[len(t.lines)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns the lines kept, including a last line that was not terminated yet.
+func (t *Tail) Bytes() []byte {
+	return t.Last(t.max + 1)
+}
+
+// Last returns the last n lines kept. A last line that was not terminated yet counts as one of them.
+func (t *Tail) Last(n int) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.partial) > 0 {
+		n--
+	}
+	lines := t.lines
+	if n < 0 {
+		n = 0
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.Write(l)
+	}
+	buf.Write(t.partial)
+	return buf.Bytes()
+}
+
+// lineEnd returns a copy of the last MaxLineLength bytes of the line.
+func lineEnd(line []byte) []byte {
+	if len(line) > MaxLineLength {
+		line = line[len(line)-MaxLineLength:]
+	}
+	return append([]byte(nil), line...)
+}
+
+// Reset removes all lines kept.
+func (t *Tail) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines, t.partial = nil, nil
+}
@@ -0,0 +1,89 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		bytes  string
+		// last is the output of Last, by the number of lines passed.
+		last map[int]string
+	}{
+		{
+			name:   "empty",
+			max:    3,
+			bytes:  "",
+			last:   map[int]string{1: ""},
+			writes: nil,
+		},
+		{
+			name:   "fewer lines than max",
+			max:    3,
+			writes: []string{"a\n", "b\n"},
+			bytes:  "a\nb\n",
+			last:   map[int]string{1: "b\n", 2: "a\nb\n", 5: "a\nb\n"},
+		},
+		{
+			name:   "more lines than max",
+			max:    2,
+			writes: []string{"a\nb\n", "c\nd\n"},
+			bytes:  "c\nd\n",
+			last:   map[int]string{1: "d\n", 3: "c\nd\n"},
+		},
+		{
+			name:   "lines split across writes",
+			max:    3,
+			writes: []string{"a", "b\nc", "d\n"},
+			bytes:  "ab\ncd\n",
+			last:   map[int]string{1: "cd\n"},
+		},
+		{
+			name:   "unterminated last line",
+			max:    2,
+			writes: []string{"a\nb\nc\nd"},
+			bytes:  "b\nc\nd",
+			last:   map[int]string{1: "d", 2: "c\nd"},
+		},
+		{
+			name:   "line longer than max length",
+			max:    2,
+			writes: []string{"a\n", strings.Repeat("b", MaxLineLength) + "c\n"},
+			bytes:  "a\n" + strings.Repeat("b", MaxLineLength-2) + "c\n",
+			last:   map[int]string{1: strings.Repeat("b", MaxLineLength-2) + "c\n"},
+		},
+		{
+			name:   "unterminated line longer than max length",
+			max:    2,
+			writes: []string{"a\n", strings.Repeat("b", MaxLineLength), "c"},
+			bytes:  "a\n" + strings.Repeat("b", MaxLineLength-1) + "c",
+			last:   map[int]string{1: strings.Repeat("b", MaxLineLength-1) + "c"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tail := NewTail(test.max)
+			for _, w := range test.writes {
+				if n, err := tail.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("expected %d bytes to be written, got %d (%v)", len(w), n, err)
+				}
+			}
+			if got := string(tail.Bytes()); got != test.bytes {
+				t.Errorf("expected %q, got %q", test.bytes, got)
+			}
+			for n, want := range test.last {
+				if got := string(tail.Last(n)); got != want {
+					t.Errorf("expected last %d line(s) to be %q, got %q", n, want, got)
+				}
+			}
+			tail.Reset()
+			if got := tail.Bytes(); len(got) != 0 {
+				t.Errorf("expected nothing after resetting, got %q", got)
+			}
+		})
+	}
+}
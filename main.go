@@ -1,279 +1,335 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/rs/zerolog"
-	"github.com/saddlemc/launcher/bundler"
+	"github.com/saddlemc/launcher/cache"
 	"github.com/saddlemc/launcher/config"
-	"github.com/saddlemc/launcher/plugin"
-	"github.com/saddlemc/launcher/plugin/provider"
+	"github.com/saddlemc/launcher/control"
+	"github.com/saddlemc/launcher/launcher"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
-	"runtime"
-	"strings"
 	"syscall"
 	"time"
 )
 
 func main() {
-	// The code currently found in the program, and especially this main function, might currently be a bit messy. This
-	// is something that is planned to be worked on to improve it in the future.
-
+	// The logging flags are accepted by every command. Until saddle.toml is loaded, only these flags configure the
+	// logger.
+	args, lf, err := extractLogFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logging = lf
 	var logger *zerolog.Logger
 	{
-		l := zerolog.New(os.Stdout).
-			Level(zerolog.DebugLevel).
-			Output(zerolog.ConsoleWriter{
-				Out:          os.Stdout,
-				PartsExclude: []string{zerolog.TimestampFieldName},
-			})
+		l, err := newLogger(logging, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
 		logger = &l
 	}
 
-	// Get all flags. They may override some settings in the configuration.
-	flagOut := flag.String("out", "",
-		"Specifies an output file name for the server binary.",
-	)
-	flagRecompile := flag.Bool("recompile", false,
-		"If set to true, the server will always be recompiled.",
-	)
-	flag.Parse()
+	// The first argument may select a command. If it does not, the server is built and run, which is what the launcher
+	// does by default.
+	name := "run"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		}
+	}
+	commands[name](logger, args)
+}
 
-	logger.Debug().Msgf("Reading saddle.toml...")
-	cfg := config.GetOrMakeConfig(logger, "saddle.toml")
-	if *flagOut != "" {
-		cfg.Bundler.Path = *flagOut
+// command is a subcommand of the launcher. It receives all arguments that follow the name of the command.
+type command = func(logger *zerolog.Logger, args []string)
+
+// commands contains all commands of the launcher, by their name.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run":      runCommand,
+		"build":    buildCommand,
+		"cache":    cacheCommand,
+		"config":   configCommand,
+		"ctl":      ctlCommand,
+		"export":   exportCommand,
+		"generate": generateCommand,
+		"lock":     lockCommand,
+		"rollback": rollbackCommand,
+		"start":    startCommand,
+		"status":   statusCommand,
+		"stop":     stopCommand,
+		"vendor":   vendorCommand,
 	}
+}
 
-	// On Windows, a '.exe' is required after the server executable.
-	outFile, err := filepath.Abs(cfg.Bundler.Path)
-	if err != nil {
-		logger.Panic().Msgf("Unable to get current working directory.")
+// runFlags holds the flags of the commands that run the server.
+type runFlags struct {
+	buildFlags
+	noConsole *bool
+	pidFile   *string
+}
+
+// addRunFlags adds the flags of the commands that run the server to the flag set.
+func addRunFlags(flags *flag.FlagSet) runFlags {
+	return runFlags{
+		buildFlags: addBuildFlags(flags),
+		noConsole: flags.Bool("no-console", false,
+			"If set to true, the input is passed to the server as it is, and launcher commands such as ':restart' "+
+				"can not be used.",
+		),
+		pidFile: flags.String("pid-file", "",
+			"If set, the process ID of the launcher is written to this file while it runs.",
+		),
 	}
-	if runtime.GOOS == "windows" {
-		if strings.ToLower(filepath.Ext(outFile)) != ".exe" {
-			outFile += ".exe"
+}
+
+// runCommand builds the server if needed, and then runs it.
+func runCommand(logger *zerolog.Logger, args []string) {
+	// Get all flags. They may override some settings in the configuration.
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	rf := addRunFlags(flags)
+	bf := rf.buildFlags
+	_ = flags.Parse(args)
+
+	if *rf.pidFile != "" {
+		if err := writePidFile(*rf.pidFile); err != nil {
+			logger.Fatal().Msgf("Unable to write pid file: %v", err)
 		}
+		defer removePidFile(*rf.pidFile)
 	}
 
-	logger.Info().Msgf("Checking for updates...")
+	// When ctrl+c is pressed, the context is cancelled, after which the server is given some time to shut down so the
+	// user can see all the output.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	logger.Debug().Msgf("Parsing plugins...")
-	provider.RegisterAll()
-	plugins, err := plugin.ParseAll(cfg.Plugin)
+	plan := resolvePlan(ctx, logger, bf)
+	res, err := launcher.Build(ctx, plan)
+	// The toolchain is no longer needed once the server is built, so any temporary files holding credentials are
+	// removed before the server is started, or before exiting if it could not be built.
+	_ = plan.Close()
 	if err != nil {
-		logger.Fatal().Msgf("Error trying to parse plugins: %v", err)
+		logger.Fatal().Msgf("Could not build server: %v", err)
 	}
 
-	logger.Debug().Msgf("Reading saddle.lock...")
-	// Get the current lockfile and also make a new lockfile. After checking plugin versions, the two will be compared
-	// to see if the already present executable is outdated.
-	needsRebuilding := false
-	lock, ok := config.GetLock(logger, "saddle.lock")
-	if !ok {
-		// If the lockfile could not successfully be loaded we rebuild the server regardless.
-		needsRebuilding = true
-	}
-	newLock := config.LockFile{
-		Version:   config.LockVersion,
-		Api:       cfg.Server.Api,
-		Dragonfly: cfg.Server.Dragonfly,
-		Plugins:   map[string]string{},
+	// The output of the server is also written to log files, so that it can be looked at after the server crashed.
+	out, err := launcher.OpenOutputLog(plan.Options.Config)
+	if err != nil {
+		logger.Error().Msgf("Unable to open server log file, the server output will not be saved: %v", err)
+	} else if out != nil {
+		defer out.Close()
 	}
-	pluginModules := make([]plugin.Module, 0, len(plugins))
-	for num, pl := range plugins {
-		latest, err := pl.Latest()
+
+	// Unless disabled, the launcher owns the terminal while the server runs, so that launcher commands can be typed.
+	// Messages are then logged above the line being typed.
+	var sc *serverConsole
+	if !*rf.noConsole {
+		sc = newServerConsole()
+		defer sc.Close()
+		logOutput, commandOutput = sc.con.Writer(os.Stdout), sc.con.Writer(os.Stderr)
+		l, err := newLogger(logging, plan.Options.Config)
 		if err != nil {
-			logger.Fatal().Msgf("Error trying to fetch latest version for plugin entry #%d: %v", num, err)
-		}
-		if x, ok := lock.Plugins[latest.Module]; !ok || x != latest.Checksum {
-			needsRebuilding = true
-			err = pl.Pull()
-			if err != nil {
-				logger.Fatal().Msgf("Error trying to update plugin entry #%d: %v", num, err)
-			}
+			logger.Fatal().Msgf("Invalid logging settings: %v", err)
 		}
-
-		newLock.Plugins[latest.Module] = latest.Checksum
-		pluginModules = append(pluginModules, pl.Module())
+		*logger = l.Hook(sc)
 	}
 
-	// Check if the file exist and can be accessed. If the file does not exist, we always have to rebuild the server.
-	if _, err = os.Stat(outFile); os.IsNotExist(err) {
-		logger.Debug().Msgf("No server binary detected, force rebuilding server.")
-		needsRebuilding = true
-	} else if err != nil {
-		logger.Error().Msgf("Unable to access output location: %s", err)
+	// Run the server. The session allows the console and the control socket to restart, rebuild or stop it.
+	logger = phase(logger, "run")
+	sess := newSession(plan, res.Binary)
+	defer sess.close()
+	if sc != nil {
+		sc.start(logger, sess)
 	}
-	// Rebuilt the server is there was an update or if the '--recompile' flag was passed.
-	if needsRebuilding || *flagRecompile {
-		logger.Info().Msgf("Rebuilding server...")
-		buildStart := time.Now()
-		// Create a temporary directory to build the server in.
-		temp, err := os.MkdirTemp("", "saddle_bundler_*")
-		if err != nil {
-			logger.Fatal().Msgf("Could not create temporary directory: %v", err)
-		}
-		logger.Debug().Msgf("Created temporary directory '%s'.", temp)
-		// Be sure to remove the temporary directory after creating it.
-		defer os.RemoveAll(temp)
-
-		logger.Debug().Msgf("Bundling plugins...")
-		err = bundler.Bundle(makeBundleConfig(logger, cfg, temp, pluginModules))
+	if cfg := plan.Options.Config; cfg.Control.Enabled {
+		srv, err := control.Listen(cfg.Control.Socket, controlHandler{logger: logger, sess: sess})
 		if err != nil {
-			logger.Fatal().Msgf("Could not bundle plugins: %v", err)
+			logger.Error().Msgf("Unable to open control socket: %v", err)
+		} else {
+			logger.Debug().Msgf("Listening on control socket '%s'.", cfg.Control.Socket)
+			defer srv.Close()
 		}
+	}
 
-		{
-			logger.Debug().Msgf("Compiling server...")
-			cmd := exec.Command("go", "mod", "tidy")
-			cmd.Dir = temp
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-			if err != nil {
-				panic(err)
+	// If the server was just replaced by a new build and crashes shortly after starting, it may be rolled back
+	// automatically to the previous build.
+	installed := res.Installed
+	capture := newCrashCapture()
+	for {
+		window := time.Duration(plan.Options.Config.Bundler.AutoRollback) * time.Second
+		start := time.Now()
+		// A crash bundle only holds the output of the run of the server that crashed.
+		capture.reset()
+		binary := res.Binary
+		req, err := sess.run(ctx, func(ctx context.Context, started func(pid int)) error {
+			var (
+				stdin          io.Reader = os.Stdin
+				stdout, stderr io.Writer = os.Stdout, os.Stderr
+			)
+			if sc != nil {
+				r, err := sc.input()
+				if err != nil {
+					return err
+				}
+				defer r.Close()
+				// The output of the server is written above the line being typed.
+				stdin, stdout, stderr = r, sc.con.Writer(os.Stdout), sc.con.Writer(os.Stderr)
 			}
-
-			cmd = exec.Command("go", "build", "-o", outFile)
-			cmd.Dir = temp
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-			if err != nil {
-				panic(err)
+			stdout, stderr = io.MultiWriter(stdout, sess.output), io.MultiWriter(stderr, sess.output)
+			return runServer(ctx, binary, stdin, stdout, stderr, out, capture, started)
+		})
+		switch req.action {
+		case actionStop:
+			req.finish(nil)
+			return
+		case actionRestart, actionRebuild:
+			var reqErr error
+			if req.action == actionRebuild {
+				if p, r, ok := rebuild(ctx, logger, bf); ok {
+					plan, res, installed = p, r, r.Installed
+					sess.update(plan, res.Binary)
+				} else {
+					reqErr = errors.New("the server could not be rebuilt, the previous server was started again")
+					logger.Warn().Msgf("Starting the previous server again...")
+				}
 			}
+			// The requester is told about the result once the server was started again.
+			sess.finishOnStart(req, reqErr)
+			if out != nil {
+				_ = out.Rotate()
+			}
+			continue
 		}
-		logger.Info().Msgf("Done! Finished building in %.3f seconds.", time.Now().Sub(buildStart).Seconds())
 
-		// The server has been built successfully. Now store the build information as the new lock file.
-		logger.Debug().Msgf("Writing saddle.lock...")
-		data, err := json.Marshal(newLock)
-		if err != nil {
-			logger.Fatal().Msgf("Could not encode saddle.lock: %v", err)
+		if exitErr, ok := err.(*exec.ExitError); ok && ctx.Err() != nil && exitErr.ExitCode() == -1 {
+			// The launcher was stopped, and the server was terminated by the interrupt it was sent rather than
+			// shutting down by itself. This is not a crash.
+			logger.Info().Msgf("The server was stopped.")
+			return
 		}
-
-		f, err := os.Create("saddle.lock")
-		if err != nil {
-			logger.Fatal().Msgf("Could not open saddle.lock: %v", err)
+		if _, ok := err.(*exec.ExitError); ok && installed && ctx.Err() == nil && window > 0 && time.Since(start) < window {
+			fmt.Println("")
+			saveCrashLog(logger, out)
+			reportPanic(logger, plan, capture)
+			if path := writeCrashBundle(logger, plan, capture); path != "" {
+				logger.Error().Msgf("A crash bundle of the new server was written to '%s'.", path)
+			}
+			logger.Error().Msgf("The new server crashed within %s after being built, rolling back to the previous build...", window)
+			if previous, ok := autoRollback(logger, plan.Options.Config, res.Lock); ok {
+				// From now on, the plan and lock describe the build that was restored rather than the one that crashed.
+				plan, res.Lock = plan.Restored(previous), previous
+				sess.update(plan, res.Binary)
+				if out != nil {
+					_ = out.Rotate()
+				}
+				installed = false
+				continue
+			}
 		}
-		defer f.Close()
-
-		_, err = f.Write(data)
-		if err != nil {
-			logger.Fatal().Msgf("Could not write saddle.lock: %v", err)
+		if _, ok := err.(*exec.ExitError); err != nil && ok {
+			fmt.Println("")
+			// The crash bundle holds everything needed to report the crash, so it is preferred over the crash log.
+			report := "the entire error message above, as well as your saddle.lock file at the time of the error"
+			crashLog := saveCrashLog(logger, out)
+			reportPanic(logger, plan, capture)
+			if path := writeCrashBundle(logger, plan, capture); path != "" {
+				report = fmt.Sprintf("the crash bundle saved at '%s'", path)
+			} else if crashLog != "" {
+				report = fmt.Sprintf("the crash log saved at '%s', as well as your saddle.lock file at the time of the error", crashLog)
+			}
+			logger.Fatal().Msgf(
+				"A fatal error caused the server to shut down unexpectedly. When reporting this error, "+
+					"please include %s. Consider trying to find the probable cause before opening an issue for the "+
+					"correct plugin.", report,
+			)
+		} else if err != nil {
+			logger.Fatal().Msgf("Error shutting down server: %s", err)
 		}
+		return
 	}
+}
 
-	// Run the server.
-	{
-		cmd := exec.Command(outFile)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Dir = filepath.Dir(outFile)
-		err = cmd.Start()
-		if err != nil {
-			logger.Fatal().Msg(err.Error())
-		}
-
-		// Wait for the program to end, and report any error that might have occurred.
-		shutdown := make(chan error)
-		go func() {
-			shutdown <- cmd.Wait()
-		}()
-
-		// When ctrl+c is pressed, make sure to wait for the server to close so the user can see all the output. We wait
-		// for up to 10 seconds for this shutdown to be successful before the server is forcibly killed.
-		timeout := make(chan struct{})
-		go func() {
-			c := make(chan os.Signal, 2)
-			signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-			<-c
+// phase returns a copy of the logger that adds a 'phase' field to every message.
+func phase(logger *zerolog.Logger, name string) *zerolog.Logger {
+	l := logger.With().Str("phase", name).Logger()
+	return &l
+}
 
-			time.Sleep(time.Second * 10)
-			close(timeout)
-		}()
+// runServer runs the server binary until it stops, connected to the input and outputs passed. If the output log is not
+// nil, the output of the server is written to it as well. The last lines of output are kept by the capture, and started
+// is called once the server was started.
+func runServer(ctx context.Context, binary string, stdin io.Reader, stdout, stderr io.Writer, out *launcher.OutputLog, capture crashCapture, started func(pid int)) error {
+	stdout, stderr = capture.tee(stdout, stderr)
+	if out != nil {
+		stdout, stderr = out.Tee(stdout, stderr)
+	}
+	return launcher.Run(ctx, binary, launcher.RunOptions{
+		Stdin:   stdin,
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Started: started,
+	})
+}
 
-		// Either wait for the graceful shutdown or for the server to time out.
-		select {
-		case err = <-shutdown:
-			if _, ok := err.(*exec.ExitError); err != nil && ok {
-				fmt.Println("")
-				logger.Fatal().Msgf(
-					"A fatal error caused the server to shut down unexpectedly. When reporting this error, " +
-						"please include the entire error message above, as well as your saddle.lock file at the time " +
-						"of the error. Consider trying to find the probable cause before opening an issue for the " +
-						"correct plugin.",
-				)
-			} else if err != nil {
-				logger.Fatal().Msgf("Error shutting down server: %s", err)
-			}
-		case _, _ = <-timeout:
-			logger.Error().Msgf("Server shutdown took to long, killing server...")
-			err := cmd.Process.Kill()
-			if err != nil {
-				logger.Fatal().Msgf("Error killing server: %s", err)
-			}
-		}
+// saveCrashLog stores the output of the server run that crashed as the crash log, and returns its path. If the output
+// is not logged or the crash log could not be written, an empty string is returned.
+func saveCrashLog(logger *zerolog.Logger, out *launcher.OutputLog) string {
+	if out == nil {
+		return ""
 	}
+	path, err := out.Crashed()
+	if err != nil {
+		logger.Error().Msgf("Unable to save crash log: %v", err)
+		return ""
+	}
+	return path
 }
 
-func makeBundleConfig(logger *zerolog.Logger, cfg *config.Config, path string, pluginModules []plugin.Module) bundler.Settings {
-	// absIfLocal is a helper function used in this function. It makes the path absolute if not empty.
-	// todo: providing a local dragonfly or saddle location does not currently trigger a rebuild
-	absIfLocal := func(s string) string {
-		if s != "" {
-			var err error
-			s, err = filepath.Abs(s)
-			if err != nil {
-				logger.Panic().Msgf("Unable to get current working directory.")
-			}
-		}
-		return s
+// autoRollback rolls back the server after the build described by the lock crashed, and reports which changes were
+// reverted. It returns the lock of the restored build, and true if the server was successfully rolled back.
+func autoRollback(logger *zerolog.Logger, cfg *config.Config, lock config.LockFile) (config.LockFile, bool) {
+	b, err := launcher.Rollback(cfg)
+	if errors.Is(err, cache.ErrNoHistory) {
+		logger.Error().Msgf("There is no previous build to roll back to.")
+		return config.LockFile{}, false
+	} else if err != nil {
+		logger.Error().Msgf("Could not roll back server: %v", err)
+		return config.LockFile{}, false
 	}
 
-	dfReplace := absIfLocal(cfg.Server.DragonflyReplace)
-	apiReplace := absIfLocal(cfg.Server.ApiReplace)
-	// Insert dragonfly and saddle into the bundler configuration.
-	var (
-		modules = append(make([]bundler.Module, 0, len(pluginModules)+2),
-			bundler.Module{
-				Name:    "github.com/df-mc/dragonfly",
-				Version: cfg.Server.Dragonfly,
-				Replace: dfReplace,
-			},
-			bundler.Module{
-				Name:    "github.com/saddlemc/saddle",
-				Version: cfg.Server.Api,
-				Replace: apiReplace,
-			},
-		)
-		imports = append(make([]bundler.Import, 0, len(pluginModules)+1),
-			bundler.Import{
-				Package: "github.com/saddlemc/saddle",
-				Alias:   ".",
-			},
-		)
-	)
-	// Convert all the plugin information to information that the bundler accepts.
-	for _, pl := range pluginModules {
-		modules = append(modules, bundler.Module{
-			Name:    pl.Module,
-			Version: pl.Version,
-			Replace: pl.Replace,
-		})
-		imports = append(imports, bundler.Import{
-			Package: pl.Import,
-			Alias:   "_",
-		})
+	previous, _ := config.ParseLock(b.Lock)
+	changes := launcher.LockChanges(previous, lock)
+	if len(changes) == 0 {
+		logger.Warn().Msgf("Rolled back to the previous build, no plugin changes were reverted.")
+	} else {
+		logger.Warn().Msgf("Rolled back to the previous build, reverting the following changes:")
+		for _, c := range changes {
+			logger.Warn().Msgf("  %s", c)
+		}
 	}
-	return bundler.Settings{
-		Path:    path,
-		Modules: modules,
-		Imports: imports,
-		Run:     "Run()",
+	logger.Warn().Msgf("The server will not be rebuilt until saddle.toml or one of the plugins changes.")
+	return previous, true
+}
+
+// loadConfig reads the saddle.toml file in the working directory, creating it if it does not exist yet. The logger is
+// reconfigured using the [log] section of the configuration.
+func loadConfig(logger *zerolog.Logger) *config.Config {
+	logger.Debug().Msgf("Reading saddle.toml...")
+	cfg := config.GetOrMakeConfig(logger, configPath)
+	l, err := newLogger(logging, cfg)
+	if err != nil {
+		logger.Fatal().Msgf("Invalid logging settings: %v", err)
 	}
+	*logger = l
+	return cfg
 }
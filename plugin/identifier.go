@@ -1,10 +1,13 @@
 package plugin
 
 import (
+	"context"
 	"github.com/rogpeppe/go-internal/dirhash"
 	"github.com/rogpeppe/go-internal/modfile"
+	"io"
 	"os"
 	path2 "path"
+	"path/filepath"
 )
 
 // Identifier contains general info about a plugin that uniquely identifies it. It is used to store which plugins were
@@ -23,6 +26,12 @@ type Identifier struct {
 
 // ParseIdentifier parses Identifier for a local plugin. It accepts the local path of the plugin as a first parameter.
 func ParseIdentifier(path string) (Identifier, error) {
+	return ParseIdentifierContext(context.Background(), path, NopProgress{})
+}
+
+// ParseIdentifierContext parses Identifier for a local plugin like ParseIdentifier. The number of bytes hashed is
+// reported to the progress, and hashing stops if the context is cancelled.
+func ParseIdentifierContext(ctx context.Context, path string, progress Progress) (Identifier, error) {
 	f, err := os.ReadFile(path2.Join(path, "go.mod"))
 	if err != nil {
 		return Identifier{}, err
@@ -31,7 +40,35 @@ func ParseIdentifier(path string) (Identifier, error) {
 	if err != nil {
 		return Identifier{}, err
 	}
-	hash, err := dirhash.HashDir(path, "", dirhash.Hash1)
+
+	files, err := dirhash.DirFiles(path, "")
+	if err != nil {
+		return Identifier{}, err
+	}
+	var done, total int64
+	for _, file := range files {
+		if info, err := os.Stat(filepath.Join(path, file)); err == nil {
+			total += info.Size()
+		}
+	}
+	progress.Bytes(0, total)
+	hash, err := dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f, err := os.Open(filepath.Join(path, name))
+		if err != nil {
+			return nil, err
+		}
+		return &countingReader{ReadCloser: f, count: func(n int) {
+			done += int64(n)
+			// Files may have grown since their size was read, in which case the total is raised to match.
+			if done > total {
+				total = done
+			}
+			progress.Bytes(done, total)
+		}}, nil
+	})
 	if err != nil {
 		return Identifier{}, err
 	}
@@ -40,3 +77,16 @@ func ParseIdentifier(path string) (Identifier, error) {
 		Checksum: hash,
 	}, nil
 }
+
+// countingReader is an io.ReadCloser that reports the number of bytes read from it.
+type countingReader struct {
+	io.ReadCloser
+	count func(n int)
+}
+
+// Read reads from the underlying reader, reporting the number of bytes read.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.count(n)
+	return n, err
+}
@@ -1,12 +1,16 @@
 package plugin
 
 import (
-	"github.com/kr/pretty"
-	"github.com/saddlemc/launcher/config"
+	"context"
+	"errors"
 )
 
 // Plugin represents a source location for a plugin. It is responsible for making sure the plugin is downloaded and in
 // the correct location before the bundling step begins.
+//
+// Plugin is the original version of the plugin interface. New plugins should implement PluginV2 instead, which allows
+// the launcher to cancel long-running operations and to show their progress. Existing implementations are converted
+// to a PluginV2 using Upgrade.
 type Plugin interface {
 	// Latest fetches the Identifier for the latest available version that should be downloaded while respecting the
 	// configuration of the user. For example, if the user specifies "0.1.7" as a version, this function will return
@@ -22,37 +26,26 @@ type Plugin interface {
 	Module() Module
 }
 
+// PluginV2 is the second version of the Plugin interface. Its methods behave like those of Plugin, but they accept a
+// context that is cancelled if the launcher is stopped, and a Progress to which progress should be reported while
+// doing any work that may take a while.
+type PluginV2 interface {
+	// Latest behaves like Plugin.Latest.
+	Latest(ctx context.Context, progress Progress) (Identifier, error)
+	// Pull behaves like Plugin.Pull.
+	Pull(ctx context.Context, progress Progress) error
+	// Module behaves like Plugin.Module.
+	Module() Module
+}
+
+// ErrUnavailableOffline is returned by a plugin if it can not be provided while the launcher runs in offline mode,
+// for example because its source has never been downloaded.
+var ErrUnavailableOffline = errors.New("unavailable in offline mode")
+
 // Provider reads the plugin entry in the config data. If this provider type can successfully identify this plugin, it
 // should be returned. If not, nil is returned, which indicates that the function cannot load this plugin. No actual
 // loading should be done here.
 type Provider = func(info map[string]any) (Plugin, error)
 
-var providers []Provider
-
-// RegisterProvider adds a new type of provider to the list of providers. The provider that was added last will be used
-// first.
-func RegisterProvider(p Provider) {
-	providers = append(providers, p)
-}
-
-// ParseAll parses all plugins and tries to identify them. These plugins are then returned. This function does not take
-// care of making sure plugins are downloaded.
-func ParseAll(list []config.PluginInfo) ([]Plugin, error) {
-	plugins := make([]Plugin, 0, len(list))
-outerLoop:
-	for num, info := range list {
-		for _, provider := range providers {
-			plugin, err := provider(info)
-			if err != nil {
-				return nil, pretty.Errorf("unable to parse plugin entry #%d: %v", num+1, err)
-			}
-			if plugin == nil {
-				continue
-			}
-			plugins = append(plugins, plugin)
-			continue outerLoop
-		}
-		return nil, pretty.Errorf("unable to parse plugin entry #%d.\n%v", num, info)
-	}
-	return plugins, nil
-}
+// ProviderV2 is like Provider, but returns a PluginV2.
+type ProviderV2 = func(info map[string]any) (PluginV2, error)
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"context"
+)
+
+// Progress receives progress updates from a plugin while it is resolved or pulled. Its methods may be called from any
+// goroutine, but never concurrently for the same plugin.
+type Progress interface {
+	// Step reports that the plugin started a new step, described by a short message such as "downloading". Any byte
+	// progress reported before applies to the previous step.
+	Step(description string)
+	// Bytes reports that done out of total bytes of the current step have been processed. If the total is not known,
+	// total is -1.
+	Bytes(done, total int64)
+}
+
+// NopProgress is a Progress that discards all updates.
+type NopProgress struct{}
+
+// Step discards the step reported.
+func (NopProgress) Step(string) {}
+
+// Bytes discards the byte progress reported.
+func (NopProgress) Bytes(int64, int64) {}
+
+// Upgrade converts a Plugin to a PluginV2. As the original plugin does not support cancellation, the context is only
+// checked before calling its methods. A single step is reported for each method.
+func Upgrade(p Plugin) PluginV2 {
+	return upgraded{p: p}
+}
+
+// UpgradeProvider converts a Provider to a ProviderV2, upgrading all plugins it returns using Upgrade.
+func UpgradeProvider(p Provider) ProviderV2 {
+	return func(info map[string]any) (PluginV2, error) {
+		pl, err := p(info)
+		if pl == nil || err != nil {
+			return nil, err
+		}
+		return Upgrade(pl), nil
+	}
+}
+
+// upgraded is a Plugin that was upgraded to a PluginV2.
+type upgraded struct {
+	p Plugin
+}
+
+// Latest checks that the context is not done before returning the latest version of the plugin.
+func (u upgraded) Latest(ctx context.Context, progress Progress) (Identifier, error) {
+	if err := ctx.Err(); err != nil {
+		return Identifier{}, err
+	}
+	progress.Step("checking for updates")
+	return u.p.Latest()
+}
+
+// Pull checks that the context is not done before pulling the plugin.
+func (u upgraded) Pull(ctx context.Context, progress Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	progress.Step("updating")
+	return u.p.Pull()
+}
+
+// Module returns the module of the plugin.
+func (u upgraded) Module() Module {
+	return u.p.Module()
+}
@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/saddlemc/launcher/plugin"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExternalPrefix is the prefix of the name of executables that provide plugins. A plugin entry with the key
+// `provider = "name"` is provided by the executable named ExternalPrefix + "name", which is looked up in PATH.
+const ExternalPrefix = "saddle-provider-"
+
+// ExternalProtocolVersion is the version of the protocol spoken with external providers. It is sent with every request.
+const ExternalProtocolVersion = 1
+
+// ExternalPlugin is a plugin that is provided by an external executable. Every method of the plugin runs the executable
+// once, writing a single JSON request to its stdin. The executable writes JSON messages to its stdout, one per line,
+// to report progress, and ends with a message holding either the result or an error.
+type ExternalPlugin struct {
+	name, executable string
+	entry            map[string]any
+	opts             Options
+
+	identifier plugin.Identifier
+	module     plugin.Module
+}
+
+// ExternalProvider returns a provider for plugin entries that have a 'provider' key, which are provided by an external
+// executable.
+func ExternalProvider(opts Options) plugin.ProviderV2 {
+	return func(info map[string]any) (plugin.PluginV2, error) {
+		e, err := newExternalPlugin(info, opts)
+		if e == nil {
+			// Returning e directly would return a non-nil interface holding a nil pointer.
+			return nil, err
+		}
+		return e, nil
+	}
+}
+
+// ExternalKeys returns a function returning the keys that an entry for an external provider may have besides
+// 'provider'. Every provider declares its own keys, which are requested using the "keys" method.
+func ExternalKeys(opts Options) func(info map[string]any) ([]string, error) {
+	return func(info map[string]any) ([]string, error) {
+		e, err := newExternalPlugin(info, opts)
+		if e == nil {
+			return nil, err
+		}
+		var res struct {
+			Keys []string `json:"keys"`
+		}
+		if err = e.call(context.Background(), plugin.NopProgress{}, "keys", &res); err != nil {
+			return nil, err
+		}
+		return res.Keys, nil
+	}
+}
+
+// newExternalPlugin returns the plugin for an entry with a 'provider' key. If the entry has no such key, nil is
+// returned without an error.
+func newExternalPlugin(info map[string]any, opts Options) (*ExternalPlugin, error) {
+	n, ok := info["provider"]
+	if !ok {
+		return nil, nil
+	}
+	name, ok := n.(string)
+	if !ok || name == "" {
+		return nil, errors.New("plugin provider must be a name surrounded by \"\"")
+	}
+	executable, err := exec.LookPath(ExternalPrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("provider '%s' not found: no executable named '%s' in PATH", name, ExternalPrefix+name)
+	}
+	return &ExternalPlugin{
+		name:       name,
+		executable: executable,
+		entry:      info,
+		opts:       opts,
+	}, nil
+}
+
+// externalRequest is the request written to the stdin of an external provider.
+type externalRequest struct {
+	// Version is the version of the protocol, ExternalProtocolVersion.
+	Version int `json:"version"`
+	// Method is the method called: "keys", "latest", "module" or "pull".
+	Method string `json:"method"`
+	// Entry is the plugin entry in saddle.toml, including the 'provider' key.
+	Entry map[string]any `json:"entry"`
+	// Offline is true if the launcher runs in offline mode, in which case the network should not be used.
+	Offline bool `json:"offline"`
+	// Identifier is the identifier returned by the "latest" method. It is only set for the "module" and "pull" methods.
+	Identifier *externalIdentifier `json:"identifier,omitempty"`
+}
+
+// externalMessage is a message written by an external provider to its stdout. Any message may report progress, and
+// the last message must set either Result or Error.
+type externalMessage struct {
+	// Step reports that the provider started a new step.
+	Step string `json:"step,omitempty"`
+	// Done and Total report the number of bytes processed in the current step. Total is -1 if it is not known.
+	Done  *int64 `json:"done,omitempty"`
+	Total *int64 `json:"total,omitempty"`
+	// Result is the result of the method. For "keys", it holds the keys accepted, for "latest", it is an identifier and
+	// for "module", it is a module. The "pull" method should return an empty object.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is set if the method failed. If the error is "unavailable-offline", the plugin is reported as not being
+	// available in offline mode.
+	Error string `json:"error,omitempty"`
+}
+
+// externalIdentifier is the plugin.Identifier as encoded in the protocol.
+type externalIdentifier struct {
+	Module   string `json:"module"`
+	Checksum string `json:"checksum"`
+}
+
+// externalModule is the plugin.Module as encoded in the protocol.
+type externalModule struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Replace string `json:"replace"`
+	Import  string `json:"import"`
+}
+
+func (e *ExternalPlugin) Latest(ctx context.Context, progress plugin.Progress) (plugin.Identifier, error) {
+	var id externalIdentifier
+	if err := e.call(ctx, progress, "latest", &id); err != nil {
+		return plugin.Identifier{}, err
+	}
+	if id.Module == "" || id.Checksum == "" {
+		return plugin.Identifier{}, fmt.Errorf("provider '%s' returned an identifier without module or checksum", e.name)
+	}
+	e.identifier = plugin.Identifier{Module: id.Module, Checksum: id.Checksum}
+
+	// Module can not return an error, so the module is already requested here. It must describe where the plugin will
+	// be found once it is pulled.
+	var mod externalModule
+	if err := e.call(ctx, progress, "module", &mod); err != nil {
+		return plugin.Identifier{}, err
+	}
+	if mod.Replace != "" {
+		replace, err := filepath.Abs(mod.Replace)
+		if err != nil {
+			return plugin.Identifier{}, err
+		}
+		mod.Replace = replace
+	}
+	if mod.Module == "" {
+		mod.Module = id.Module
+	}
+	if mod.Import == "" {
+		mod.Import = mod.Module + "/import"
+	}
+	e.module = plugin.Module{Module: mod.Module, Version: mod.Version, Replace: mod.Replace, Import: mod.Import}
+	return e.identifier, nil
+}
+
+func (e *ExternalPlugin) Pull(ctx context.Context, progress plugin.Progress) error {
+	return e.call(ctx, progress, "pull", nil)
+}
+
+func (e *ExternalPlugin) Module() plugin.Module {
+	return e.module
+}
+
+// call runs the executable of the provider for a method, decoding the result into v if it is not nil.
+func (e *ExternalPlugin) call(ctx context.Context, progress plugin.Progress, method string, v any) error {
+	req := externalRequest{
+		Version: ExternalProtocolVersion,
+		Method:  method,
+		Entry:   e.entry,
+		Offline: e.opts.Offline,
+	}
+	if method == "module" || method == "pull" {
+		req.Identifier = &externalIdentifier{Module: e.identifier.Module, Checksum: e.identifier.Checksum}
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("error encoding request for provider '%s': %w", e.name, err)
+	}
+
+	cmd := exec.CommandContext(ctx, e.executable)
+	cmd.Stdin = bytes.NewReader(in)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	// The provider may use the Go toolchain itself, so it is given the same environment as the toolchain.
+	cmd.Env = os.Environ()
+	if e.opts.Go != nil {
+		cmd.Env = e.opts.Go.Command().Env
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("error starting provider '%s': %w", e.name, err)
+	}
+
+	var last externalMessage
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var msg externalMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return fmt.Errorf("provider '%s' wrote an invalid message: %w", e.name, err)
+		}
+		if msg.Step != "" {
+			progress.Step(msg.Step)
+		}
+		if msg.Done != nil {
+			done, total := *msg.Done, int64(-1)
+			if msg.Total != nil {
+				total = *msg.Total
+			}
+			if done < 0 || total < -1 {
+				_ = cmd.Process.Kill()
+				_ = cmd.Wait()
+				return fmt.Errorf("provider '%s' reported invalid progress: %d out of %d bytes", e.name, done, total)
+			}
+			// A provider may find more bytes than it expected, in which case the total is raised to match.
+			if total >= 0 && done > total {
+				total = done
+			}
+			progress.Bytes(done, total)
+		}
+		last = msg
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	switch {
+	case last.Error == "unavailable-offline":
+		return fmt.Errorf("provider '%s': %w", e.name, plugin.ErrUnavailableOffline)
+	case last.Error != "":
+		return fmt.Errorf("provider '%s': %s", e.name, last.Error)
+	case err != nil:
+		return fmt.Errorf("provider '%s' failed: %w: %s", e.name, err, strings.TrimSpace(stderr.String()))
+	case last.Result == nil:
+		return fmt.Errorf("provider '%s' exited without a result for method '%s'", e.name, method)
+	case v == nil:
+		return nil
+	}
+	if err := json.Unmarshal(last.Result, v); err != nil {
+		return fmt.Errorf("provider '%s' returned an invalid result for method '%s': %w", e.name, method, err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"github.com/saddlemc/launcher/plugin"
 	"os"
@@ -12,7 +13,7 @@ type LocalPlugin struct {
 	path       string
 }
 
-func LocalProvider(info map[string]any) (plugin.Plugin, error) {
+func LocalProvider(info map[string]any) (plugin.PluginV2, error) {
 	p, ok := info["local"]
 	if !ok {
 		return nil, nil
@@ -26,23 +27,26 @@ func LocalProvider(info map[string]any) (plugin.Plugin, error) {
 	if err != nil {
 		panic(err)
 	}
-	identifier, err := plugin.ParseIdentifier(path)
-	if err != nil {
-		return nil, err
-	}
 	return &LocalPlugin{
-		identifier: identifier,
-		path:       path,
+		path: path,
 	}, nil
 }
 
-func (l *LocalPlugin) Latest() (plugin.Identifier, error) {
+func (l *LocalPlugin) Latest(ctx context.Context, progress plugin.Progress) (plugin.Identifier, error) {
+	// The identifier is a hash of the entire directory, so it is computed here rather than when parsing the plugin, as
+	// hashing a large directory may take a while.
+	progress.Step("hashing")
+	identifier, err := plugin.ParseIdentifierContext(ctx, l.path, progress)
+	if err != nil {
+		return plugin.Identifier{}, err
+	}
+	l.identifier = identifier
 	return l.identifier, nil
 }
 
-func (l *LocalPlugin) Pull() error {
+func (l *LocalPlugin) Pull(context.Context, plugin.Progress) error {
 	if _, err := os.Stat(l.path); os.IsNotExist(err) {
-		return fmt.Errorf("path '%w' does not exist", l.path)
+		return fmt.Errorf("path '%s' does not exist", l.path)
 	}
 	// Nothing else needs to be done as the plugin is already locally downloaded.
 	return nil
@@ -1,55 +1,146 @@
 package provider
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/rogpeppe/go-internal/semver"
 	"github.com/saddlemc/launcher/plugin"
+	"os"
+	"strings"
 )
 
 type ModulePlugin struct {
 	name, version string
+	// resolved is the exact version that the requested version resolved to. It is set by ModulePlugin.Latest().
+	resolved string
+	opts     Options
 }
 
-func ModuleProvider(info map[string]any) (plugin.Plugin, error) {
-	n, ok := info["module"]
-	if !ok {
-		return nil, nil
-	}
-	name, ok := n.(string)
-	if !ok {
-		return nil, nil
-	}
-	version := "latest"
-	if x, ok := info["version"]; ok {
-		v, ok := x.(string)
+// ModuleProvider returns a provider for plugins that are go modules. The Go toolchain is used to resolve the version of
+// a plugin, so that the lookups respect the same proxy and credential settings as the build itself.
+func ModuleProvider(opts Options) plugin.ProviderV2 {
+	return func(info map[string]any) (plugin.PluginV2, error) {
+		n, ok := info["module"]
+		if !ok {
+			return nil, nil
+		}
+		name, ok := n.(string)
 		if !ok {
-			return nil, errors.New("plugin version must be surrounded by \"\"")
+			return nil, nil
 		}
-		version = v
+		version := "latest"
+		if x, ok := info["version"]; ok {
+			v, ok := x.(string)
+			if !ok {
+				return nil, errors.New("plugin version must be surrounded by \"\"")
+			}
+			version = v
+		}
+		return &ModulePlugin{
+			name:    name,
+			version: version,
+			opts:    opts,
+		}, nil
 	}
-	// todo: convert tag into commit hash instead of abusing go modules
-	return ModulePlugin{
-		name:    name,
-		version: version,
-	}, nil
 }
 
-func (m ModulePlugin) Latest() (plugin.Identifier, error) {
+func (m *ModulePlugin) Latest(ctx context.Context, progress plugin.Progress) (plugin.Identifier, error) {
+	// Versions such as "latest" or a branch name are resolved to an exact version, so that any update to the plugin
+	// causes the identifier to change.
+	progress.Step("resolving " + m.version)
+	version, err := ResolveModule(ctx, m.opts, m.name, m.version)
+	if err != nil {
+		return plugin.Identifier{}, err
+	}
+	m.resolved = version
 	return plugin.Identifier{
 		Module:   m.name,
-		Checksum: "git:" + m.version,
+		Checksum: "git:" + m.resolved,
 	}, nil
 }
 
-func (m ModulePlugin) Pull() error {
-	// The module will be downloaded by go modules.
+func (m *ModulePlugin) Pull(ctx context.Context, progress plugin.Progress) error {
+	// The module would also be downloaded by go modules while building, but downloading it here allows the progress to
+	// be shown for each plugin separately.
+	progress.Step("downloading " + m.resolved)
+	cmd := m.opts.Go.CommandContext(ctx, "mod", "download", "-json", m.name+"@"+m.resolved)
+	out, err := cmd.Output()
+	var info struct {
+		Zip   string
+		Error string
+	}
+	// The output is also written if the download failed, in which case it holds the error.
+	if jsonErr := json.Unmarshal(out, &info); jsonErr == nil && info.Error != "" {
+		return fmt.Errorf("error downloading %s@%s: %s", m.name, m.resolved, info.Error)
+	} else if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error downloading %s@%s: %w", m.name, m.resolved, err)
+	}
+	if stat, err := os.Stat(info.Zip); err == nil {
+		progress.Bytes(stat.Size(), stat.Size())
+	}
 	return nil
 }
 
-func (m ModulePlugin) Module() plugin.Module {
+func (m *ModulePlugin) Module() plugin.Module {
+	version := m.resolved
+	if version == "" {
+		version = m.version
+	}
 	return plugin.Module{
 		Module:  m.name,
-		Version: m.version,
+		Version: version,
 		Replace: "",
 		Import:  m.name + "/import",
 	}
 }
+
+// ResolveModule resolves the version of a module, which may be any version query accepted by the go command, to an
+// exact version. Exact versions are returned as they are, and queries resolve to the version pinned in the lock file if
+// there is one, so that the go command is only used for queries that were not resolved before. In offline mode, an
+// error wrapping plugin.ErrUnavailableOffline is returned if the module is not present in the module cache.
+func ResolveModule(ctx context.Context, opts Options, name, version string) (string, error) {
+	query := version
+	if !exactVersion(version) {
+		if pinned, ok := opts.Pinned[name]; ok {
+			query = pinned
+		} else if opts.Offline {
+			return "", fmt.Errorf("%s@%s: %w: no version is pinned in saddle.lock", name, version, plugin.ErrUnavailableOffline)
+		}
+	}
+	// Only offline mode has to look up exact versions, to make sure that the module is present in the module cache.
+	if exactVersion(query) && !opts.Offline {
+		return query, nil
+	}
+
+	cmd := opts.Go.CommandContext(ctx, "list", "-m", "-json", name+"@"+query)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if opts.Offline {
+			return "", fmt.Errorf("%s@%s: %w: not present in the module cache", name, query, plugin.ErrUnavailableOffline)
+		}
+		return "", fmt.Errorf("error resolving %s@%s: %s", name, query, strings.TrimSpace(stderr.String()))
+	}
+	var info struct {
+		Version string
+	}
+	if err = json.Unmarshal(out, &info); err != nil {
+		return "", fmt.Errorf("error resolving %s@%s: %w", name, query, err)
+	}
+	return info.Version, nil
+}
+
+// exactVersion checks if the version is an exact module version rather than a query, such as 'latest' or a branch name.
+func exactVersion(version string) bool {
+	return semver.IsValid(version) && semver.Canonical(version) == version
+}
@@ -1,8 +1,42 @@
 package provider
 
-import "github.com/saddlemc/launcher/plugin"
+import (
+	"github.com/saddlemc/launcher/plugin"
+	"github.com/saddlemc/launcher/toolchain"
+)
 
-func RegisterAll() {
-	plugin.RegisterProvider(ModuleProvider)
-	plugin.RegisterProvider(LocalProvider)
+// Options configures the providers that are built into the launcher.
+type Options struct {
+	// Go is the toolchain used by providers that need to look up modules.
+	Go *toolchain.Go
+	// Offline specifies if the launcher runs in offline mode. In offline mode, no remote lookups are done and versions
+	// such as "latest" must be pinned in the lock file.
+	Offline bool
+	// Pinned contains the module versions pinned in the lock file, by module name. Versions such as "latest" resolve to
+	// the pinned version if there is one.
+	Pinned map[string]string
+}
+
+// RegisterAll registers all providers built into the launcher.
+func RegisterAll(opts Options) {
+	for _, p := range Providers(opts) {
+		plugin.Register(p)
+	}
+}
+
+// Providers returns all providers built into the launcher, without registering them.
+func Providers(opts Options) []plugin.Registration {
+	// Entries selecting an external provider may use any keys that the provider declares, so the external provider is
+	// tried first.
+	return []plugin.Registration{
+		{
+			Name:      "external",
+			Required:  []string{"provider"},
+			Keys:      []string{"provider"},
+			EntryKeys: ExternalKeys(opts),
+			Provider:  ExternalProvider(opts),
+		},
+		{Name: "module", Required: []string{"module"}, Keys: []string{"module", "version"}, Provider: ModuleProvider(opts)},
+		{Name: "local", Required: []string{"local"}, Keys: []string{"local"}, Provider: LocalProvider},
+	}
 }
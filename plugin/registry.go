@@ -0,0 +1,264 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/saddlemc/launcher/config"
+	"sort"
+	"strings"
+)
+
+// TypeKey is the key of a plugin entry that explicitly selects the provider of the plugin by its name. If it is not
+// set, the provider is detected from the other keys of the entry.
+const TypeKey = "type"
+
+// Registration describes a provider, so that it can be selected explicitly and so that the keys of plugin entries can
+// be validated.
+type Registration struct {
+	// Name is the name of the provider, which selects it when set as the 'type' of a plugin entry. Providers without a
+	// name can only be detected.
+	Name string
+	// Required holds the keys that must be set in an entry for this provider.
+	Required []string
+	// Keys holds all keys that entries for this provider may have, including the required keys. The 'type' key is
+	// always accepted. If nil, any key is accepted.
+	Keys []string
+	// EntryKeys returns the keys that a specific entry may have in addition to Keys, for providers whose keys depend on
+	// the entry, such as external providers. It may be nil.
+	EntryKeys func(info map[string]any) ([]string, error)
+	// Provider returns the plugin for an entry. When the type of the entry is not set, it must return nil if the entry
+	// is not meant for this provider.
+	Provider ProviderV2
+}
+
+// accepts returns true if entries for the provider may have the key passed.
+func (r Registration) accepts(key string) bool {
+	if r.Keys == nil || key == TypeKey {
+		return true
+	}
+	for _, k := range r.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema returns the JSON schema for plugin entries of this provider, which is used in the schema of config files. The
+// values of all keys are expected to be strings.
+func (r Registration) Schema() map[string]any {
+	properties := map[string]any{}
+	if r.Name != "" {
+		properties[TypeKey] = map[string]any{"const": r.Name}
+	}
+	for _, k := range append(append([]string{}, r.Required...), r.Keys...) {
+		properties[k] = map[string]any{"type": "string"}
+	}
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": r.Keys == nil || r.EntryKeys != nil,
+	}
+	if r.Name != "" {
+		s["title"] = r.Name
+	}
+	if len(r.Required) > 0 {
+		s["required"] = r.Required
+	}
+	return s
+}
+
+var registrations []Registration
+
+// Register adds a new type of provider to the list of providers. When detecting the provider of an entry, providers
+// are tried in the order they were registered.
+func Register(r Registration) {
+	registrations = append(registrations, r)
+}
+
+// RegisterProvider adds a new provider without a name to the list of providers. As its keys are not known, entries for
+// this provider are not validated.
+func RegisterProvider(p Provider) {
+	RegisterProviderV2(UpgradeProvider(p))
+}
+
+// RegisterProviderV2 adds a new provider that returns a PluginV2 and has no name to the list of providers.
+func RegisterProviderV2(p ProviderV2) {
+	Register(Registration{Provider: p})
+}
+
+// ParseAll parses all plugins and tries to identify them. These plugins are then returned. This function does not take
+// care of making sure plugins are downloaded.
+func ParseAll(list []config.PluginInfo) ([]PluginV2, error) {
+	return Parse(list, registrations)
+}
+
+// Parse parses all plugins like ParseAll, but using the provided list of providers instead of the registered
+// providers. Errors mention the number of the entry, starting at 1.
+func Parse(list []config.PluginInfo, providers []Registration) ([]PluginV2, error) {
+	plugins := make([]PluginV2, 0, len(list))
+	for num, info := range list {
+		pl, err := ParseEntry(info, providers)
+		if err != nil {
+			return nil, fmt.Errorf("entry #%d: %w", num+1, err)
+		}
+		plugins = append(plugins, pl)
+	}
+	return plugins, nil
+}
+
+// ParseEntry parses a single plugin entry using the provided list of providers.
+func ParseEntry(info config.PluginInfo, providers []Registration) (PluginV2, error) {
+	if t, ok := info[TypeKey]; ok {
+		name, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("'%s' must be surrounded by \"\"", TypeKey)
+		}
+		var names []string
+		for _, r := range providers {
+			if r.Name == name {
+				return parseWith(info, r)
+			}
+			if r.Name != "" {
+				names = append(names, r.Name)
+			}
+		}
+		if s := suggest(name, names); s != "" {
+			return nil, fmt.Errorf("unknown type '%s', did you mean '%s'?", name, s)
+		}
+		return nil, fmt.Errorf("unknown type '%s', must be one of: %s", name, strings.Join(names, ", "))
+	}
+
+	for _, r := range providers {
+		pl, err := r.Provider(info)
+		if err != nil {
+			return nil, err
+		}
+		if pl == nil {
+			continue
+		}
+		if err = validateKeys(info, r); err != nil {
+			return nil, err
+		}
+		return pl, nil
+	}
+
+	// No provider recognised the entry. This is usually caused by a typo, so any key that no provider accepts is
+	// reported first.
+	var known, names []string
+	for _, r := range providers {
+		known = append(known, r.Keys...)
+		if r.Name != "" {
+			names = append(names, r.Name)
+		}
+	}
+	// Providers accepting any key are skipped, as they did not recognise the entry anyway.
+	for _, key := range sortedKeys(info) {
+		accepted := false
+		for _, r := range providers {
+			accepted = accepted || (r.Keys != nil && r.accepts(key))
+		}
+		if !accepted {
+			return nil, unknownKey(key, known, "")
+		}
+	}
+	return nil, fmt.Errorf("unable to determine the type of the plugin, set '%s' to one of: %s", TypeKey,
+		strings.Join(names, ", "))
+}
+
+// parseWith parses an entry using a provider that was selected explicitly.
+func parseWith(info config.PluginInfo, r Registration) (PluginV2, error) {
+	if err := validateKeys(info, r); err != nil {
+		return nil, err
+	}
+	for _, key := range r.Required {
+		if _, ok := info[key]; !ok {
+			return nil, fmt.Errorf("missing key '%s', which is required for type '%s'", key, r.Name)
+		}
+	}
+	pl, err := r.Provider(info)
+	if err != nil {
+		return nil, err
+	}
+	if pl == nil {
+		return nil, fmt.Errorf("not a valid plugin of type '%s'", r.Name)
+	}
+	return pl, nil
+}
+
+// validateKeys returns an error if the entry has a key that the provider does not accept.
+func validateKeys(info config.PluginInfo, r Registration) error {
+	if r.EntryKeys != nil && r.Keys != nil {
+		keys, err := r.EntryKeys(info)
+		if err != nil {
+			return err
+		}
+		r.Keys = append(append([]string{}, r.Keys...), keys...)
+	}
+	for _, key := range sortedKeys(info) {
+		if !r.accepts(key) {
+			return unknownKey(key, r.Keys, r.Name)
+		}
+	}
+	return nil
+}
+
+// unknownKey returns the error for an unknown key, suggesting one of the known keys if it is similar. If the provider
+// of the entry is known, its name is passed as well.
+func unknownKey(key string, known []string, name string) error {
+	if s := suggest(key, known); s != "" {
+		return fmt.Errorf("unknown key '%s', did you mean '%s'?", key, s)
+	}
+	if name != "" {
+		return fmt.Errorf("unknown key '%s' for type '%s', accepted keys are: %s", key, name, strings.Join(known, ", "))
+	}
+	return fmt.Errorf("unknown key '%s'", key)
+}
+
+// sortedKeys returns the keys of an entry in alphabetical order, so that errors are reported consistently.
+func sortedKeys(info config.PluginInfo) []string {
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the candidate that is most similar to s, or an empty string if none of them are similar enough to
+// be a likely typo.
+func suggest(s string, candidates []string) string {
+	best, bestDist := "", len(s)/2+1
+	for _, c := range candidates {
+		if d := distance(s, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = cur[j-1] + 1
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"github.com/saddlemc/launcher/config"
+	"testing"
+)
+
+// testPlugin is a plugin returned by the providers used in tests. Its module is named after the provider.
+type testPlugin struct {
+	name string
+}
+
+func (p testPlugin) Latest(context.Context, Progress) (Identifier, error) { return Identifier{}, nil }
+func (p testPlugin) Pull(context.Context, Progress) error                 { return nil }
+func (p testPlugin) Module() Module                                       { return Module{Module: p.name} }
+
+// testProviders returns providers similar to the built-in ones: a local provider detected by the 'local' key, a git
+// provider detected by the 'git' key, and a provider without a name that accepts any entry with a 'custom' key.
+func testProviders() []Registration {
+	detect := func(name, key string) ProviderV2 {
+		return func(info map[string]any) (PluginV2, error) {
+			if _, ok := info[key]; !ok {
+				return nil, nil
+			}
+			return testPlugin{name: name}, nil
+		}
+	}
+	return []Registration{
+		{Name: "local", Required: []string{"local"}, Keys: []string{"local"}, Provider: detect("local", "local")},
+		{Name: "git", Required: []string{"git"}, Keys: []string{"git", "branch", "tag"}, Provider: detect("git", "git")},
+		{Provider: detect("custom", "custom")},
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   config.PluginInfo
+		module string
+		err    string
+	}{
+		{name: "detected", info: config.PluginInfo{"local": "./plugin"}, module: "local"},
+		{name: "detected later provider", info: config.PluginInfo{"git": "https://example.com/plugin", "tag": "v1.0.0"}, module: "git"},
+		{name: "detected without keys", info: config.PluginInfo{"custom": "x", "anything": "y"}, module: "custom"},
+		{name: "explicit type", info: config.PluginInfo{"type": "git", "git": "https://example.com/plugin"}, module: "git"},
+		{name: "type not a string", info: config.PluginInfo{"type": 1}, err: "'type' must be surrounded by \"\""},
+		{name: "type typo", info: config.PluginInfo{"type": "lcal", "local": "./plugin"}, err: "unknown type 'lcal', did you mean 'local'?"},
+		{name: "type unknown", info: config.PluginInfo{"type": "registry"}, err: "unknown type 'registry', must be one of: local, git"},
+		{name: "missing required key", info: config.PluginInfo{"type": "git", "tag": "v1.0.0"}, err: "missing key 'git', which is required for type 'git'"},
+		{name: "key typo", info: config.PluginInfo{"git": "https://example.com/plugin", "brnch": "main"}, err: "unknown key 'brnch', did you mean 'branch'?"},
+		{name: "key unknown", info: config.PluginInfo{"local": "./plugin", "version": "v1.0.0"}, err: "unknown key 'version' for type 'local', accepted keys are: local"},
+		{name: "undetected typo", info: config.PluginInfo{"loca": "./plugin"}, err: "unknown key 'loca', did you mean 'local'?"},
+		{name: "undetected", info: config.PluginInfo{"branch": "main"}, err: "unable to determine the type of the plugin, set 'type' to one of: local, git"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl, err := ParseEntry(test.info, testProviders())
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := pl.Module().Module; name != test.module {
+				t.Errorf("expected plugin of provider '%s', got '%s'", test.module, name)
+			}
+		})
+	}
+}
+
+func TestParseEntryKeys(t *testing.T) {
+	// The provider declares the keys of its entries itself, like external providers do.
+	providers := []Registration{{
+		Name:     "external",
+		Required: []string{"provider"},
+		Keys:     []string{"provider"},
+		EntryKeys: func(info map[string]any) ([]string, error) {
+			if info["provider"] == "broken" {
+				return nil, errors.New("provider 'broken' failed")
+			}
+			return []string{"artifact"}, nil
+		},
+		Provider: func(info map[string]any) (PluginV2, error) {
+			if _, ok := info["provider"]; !ok {
+				return nil, nil
+			}
+			return testPlugin{name: "external"}, nil
+		},
+	}}
+	tests := []struct {
+		name string
+		info config.PluginInfo
+		err  string
+	}{
+		{name: "declared key", info: config.PluginInfo{"provider": "artifacts", "artifact": "my-plugin"}},
+		{name: "explicit type", info: config.PluginInfo{"type": "external", "provider": "artifacts", "artifact": "my-plugin"}},
+		{name: "key typo", info: config.PluginInfo{"provider": "artifacts", "artifcat": "my-plugin"}, err: "unknown key 'artifcat', did you mean 'artifact'?"},
+		{name: "key unknown", info: config.PluginInfo{"provider": "artifacts", "version": "v1.0.0"}, err: "unknown key 'version' for type 'external', accepted keys are: provider, artifact"},
+		{name: "keys not declared", info: config.PluginInfo{"provider": "broken", "artifact": "my-plugin"}, err: "provider 'broken' failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseEntry(test.info, providers)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"local", "git", "branch", "tag", "module"}
+	tests := []struct {
+		s, want string
+	}{
+		{s: "local", want: "local"},
+		{s: "lokal", want: "local"},
+		{s: "gt", want: "git"},
+		{s: "gti", want: ""},
+		{s: "brnach", want: "branch"},
+		{s: "modul", want: "module"},
+		{s: "version", want: ""},
+		{s: "x", want: ""},
+		{s: "", want: ""},
+	}
+	for _, test := range tests {
+		if got := suggest(test.s, candidates); got != test.want {
+			t.Errorf("suggest(%q): expected %q, got %q", test.s, test.want, got)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "abc", want: 3},
+		{a: "abc", b: "", want: 3},
+		{a: "local", b: "local", want: 0},
+		{a: "local", b: "lokal", want: 1},
+		{a: "local", b: "loca", want: 1},
+		{a: "branch", b: "brnach", want: 2},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+	for _, test := range tests {
+		if got := distance(test.a, test.b); got != test.want {
+			t.Errorf("distance(%q, %q): expected %d, got %d", test.a, test.b, test.want, got)
+		}
+	}
+}
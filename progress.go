@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/plugin"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// progressBars renders the progress of each plugin while the server is resolved. If the output is a terminal, each
+// plugin gets a single line with a progress bar that is updated in place. Otherwise, every step is logged instead.
+type progressBars struct {
+	logger   *zerolog.Logger
+	out      io.Writer
+	terminal bool
+
+	mu sync.Mutex
+	// current is the plugin that the last line was rendered for, and open is true if that line has not yet been
+	// terminated.
+	current *pluginProgress
+	open    bool
+}
+
+// newProgressBars returns progress bars writing to stdout.
+func newProgressBars(logger *zerolog.Logger) *progressBars {
+	terminal := false
+	if stat, err := os.Stdout.Stat(); err == nil {
+		terminal = stat.Mode()&os.ModeCharDevice != 0
+	}
+	return &progressBars{logger: logger, out: os.Stdout, terminal: terminal}
+}
+
+// Plugin returns the progress for the plugin with the label passed. It may be used as launcher.Options.Progress.
+func (b *progressBars) Plugin(label string) plugin.Progress {
+	return &pluginProgress{bars: b, label: label, total: -1}
+}
+
+// Finish terminates the line of the last plugin, so that other output can be written.
+func (b *progressBars) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		_, _ = fmt.Fprintln(b.out)
+		b.open = false
+	}
+}
+
+// Run terminates the line of the last plugin before a message is logged. It allows the progress bars to be used as
+// a hook for loggers that write to the same output.
+func (b *progressBars) Run(*zerolog.Event, zerolog.Level, string) {
+	b.Finish()
+}
+
+// render renders the line for a plugin, terminating the line of the previous plugin if it was for another plugin.
+func (b *progressBars) render(p *pluginProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open && b.current != p {
+		_, _ = fmt.Fprintln(b.out)
+	}
+	b.current, b.open = p, true
+
+	line := fmt.Sprintf("  %s: %s", p.label, p.step)
+	if p.total > 0 {
+		const width = 20
+		percent := progressPercent(p.done, p.total)
+		filled := int(percent * width / 100)
+		line += fmt.Sprintf(" [%s%s] %3d%% %s", strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+			percent, formatSize(p.total))
+	} else if p.done > 0 {
+		line += " " + formatSize(p.done)
+	}
+	// The line is padded to clear anything left over from a longer line rendered before.
+	if len(line) < p.width {
+		line += strings.Repeat(" ", p.width-len(line))
+	}
+	p.width = len(line)
+	_, _ = fmt.Fprint(b.out, "\r"+line)
+}
+
+// pluginProgress implements plugin.Progress for a single plugin.
+type pluginProgress struct {
+	bars  *progressBars
+	label string
+
+	step        string
+	done, total int64
+	percent     int64
+	width       int
+}
+
+// Step shows the step that the plugin started, or logs it if the output is not a terminal.
+func (p *pluginProgress) Step(description string) {
+	p.step, p.done, p.total, p.percent = description, 0, -1, -1
+	if !p.bars.terminal {
+		p.bars.logger.Debug().Msgf("Plugin %s: %s...", p.label, description)
+		return
+	}
+	p.bars.render(p)
+}
+
+// Bytes shows the byte progress of the current step. Nothing is shown if the output is not a terminal.
+func (p *pluginProgress) Bytes(done, total int64) {
+	p.done, p.total = done, total
+	if !p.bars.terminal {
+		return
+	}
+	// The line is only rendered again if it visibly changed, as bytes may be reported very often.
+	percent := int64(-1)
+	if total > 0 {
+		percent = progressPercent(done, total)
+	}
+	if percent == p.percent && total > 0 {
+		return
+	}
+	p.percent = percent
+	p.bars.render(p)
+}
+
+// progressPercent returns the percentage of the total that is done, between 0 and 100. Plugins may report more bytes
+// than the total, for example if a file grew while it was read, in which case 100 is returned.
+func progressPercent(done, total int64) int64 {
+	percent := done * 100 / total
+	if percent < 0 {
+		return 0
+	} else if percent > 100 {
+		return 100
+	}
+	return percent
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/cache"
+	"github.com/saddlemc/launcher/config"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+	"text/tabwriter"
+)
+
+// rollbackCommand restores the previous server binary and saddle.lock from the build history.
+func rollbackCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	flagList := flags.Bool("list", false,
+		"If set to true, the previous builds are listed instead of being restored.",
+	)
+	_ = flags.Parse(args)
+
+	cfg := loadConfig(logger)
+	if *flagList {
+		builds, err := launcher.OpenHistory(cfg).List()
+		if err != nil {
+			logger.Fatal().Msgf("Could not read build history: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "REPLACED\tFINGERPRINT\tPLUGINS")
+		for _, b := range builds {
+			lock, _ := config.ParseLock(b.Lock)
+			_, _ = fmt.Fprintf(w, "%s\t%.12s\t%d\n",
+				b.Replaced.Format("2006-01-02 15:04:05"), lock.Fingerprint, len(lock.Plugins),
+			)
+		}
+		_ = w.Flush()
+		return
+	}
+
+	b, err := launcher.Rollback(cfg)
+	if errors.Is(err, cache.ErrNoHistory) {
+		logger.Fatal().Msgf("There is no previous build to roll back to.")
+	} else if err != nil {
+		logger.Fatal().Msgf("Could not roll back server: %v", err)
+	}
+	logger.Info().Msgf("Rolled back to the server that was replaced at %s.", b.Replaced.Format("2006-01-02 15:04:05"))
+	logger.Info().Msgf("The server will not be rebuilt until saddle.toml or one of the plugins changes.")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/saddlemc/launcher/launcher"
+	"github.com/saddlemc/launcher/logs"
+	"sync"
+	"time"
+)
+
+// action is an action that stops the server, requested through the console or the control socket.
+type action int
+
+const (
+	// actionNone means that no action was requested, so the server stopped by itself.
+	actionNone action = iota
+	// actionRestart restarts the server using the same binary.
+	actionRestart
+	// actionRebuild rebuilds the server if anything changed, and starts it again.
+	actionRebuild
+	// actionStop stops the server and the launcher.
+	actionStop
+)
+
+// sessionOutputLines is the amount of lines of output of the server kept for the control socket.
+const sessionOutputLines = 1000
+
+// request is a request for an action. If done is not nil, it receives the result once the action was carried out.
+type request struct {
+	action action
+	done   chan error
+}
+
+// finish reports the result of the request to the requester, if it waits for it.
+func (r request) finish(err error) {
+	if r.done != nil {
+		r.done <- err
+	}
+}
+
+// session holds the state of the server run by the launcher. It is shared with the console and the control socket,
+// which request actions through it.
+type session struct {
+	requests chan request
+	// output keeps the last lines of output of the server.
+	output *logs.Tail
+
+	mu      sync.Mutex
+	plan    launcher.Plan
+	binary  string
+	running bool
+	pid     int
+	started time.Time
+	// pending is the request that stopped the previous server, which is finished with pendingErr once the server was
+	// started again.
+	pending    *request
+	pendingErr error
+}
+
+// newSession returns a session running the binary built for the plan.
+func newSession(plan launcher.Plan, binary string) *session {
+	return &session{
+		requests: make(chan request),
+		output:   logs.NewTail(sessionOutputLines),
+		plan:     plan,
+		binary:   binary,
+	}
+}
+
+// request requests an action. If wait is true, it returns once the action was carried out, or once the context is done.
+// The request is only received while the server runs.
+func (s *session) request(ctx context.Context, a action, wait bool) error {
+	req := request{action: a}
+	if wait {
+		req.done = make(chan error, 1)
+	}
+	select {
+	case s.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !wait {
+		return nil
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run calls the function passed to run the server, until it stops by itself or an action is requested, in which case
+// the context passed to it is cancelled. The request that stopped the server is returned, which has actionNone if the
+// server stopped by itself. The function must call started once the server was started.
+func (s *session) run(ctx context.Context, run func(ctx context.Context, started func(pid int)) error) (request, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- run(runCtx, s.start)
+	}()
+
+	var req request
+	for {
+		select {
+		case err := <-done:
+			s.mu.Lock()
+			s.running = false
+			s.mu.Unlock()
+			return req, err
+		case r := <-s.requests:
+			if req.action != actionNone {
+				r.finish(errors.New("the server is already being stopped"))
+				continue
+			}
+			req = r
+			cancel()
+		}
+	}
+}
+
+// start records that the server was started. The request that stopped the previous server is finished now.
+func (s *session) start(pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running, s.pid, s.started = true, pid, time.Now()
+	if s.pending != nil {
+		s.pending.finish(s.pendingErr)
+		s.pending, s.pendingErr = nil, nil
+	}
+}
+
+// finishOnStart finishes the request with the error passed once the server was started again.
+func (s *session) finishOnStart(req request, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending, s.pendingErr = &req, err
+}
+
+// update replaces the plan and binary of the server after it was rebuilt.
+func (s *session) update(plan launcher.Plan, binary string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plan, s.binary = plan, binary
+}
+
+// close finishes a request that is still waiting for the server to be started, as the launcher stops.
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending != nil {
+		s.pending.finish(errors.New("the launcher stopped before the server was started again"))
+		s.pending = nil
+	}
+}
@@ -0,0 +1,167 @@
+package toolchain
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Private contains the settings used to fetch modules from private sources. None of these settings are ever written
+// to disk by the launcher, except for netrc data that is provided through NetrcData, which is written to a temporary
+// file that only the current user can read.
+type Private struct {
+	// Proxy, Private, NoSumDB and Insecure set the GOPROXY, GOPRIVATE, GONOSUMDB and GOINSECURE variables. Empty values
+	// are not set, so that the values from the environment are used instead.
+	Proxy, Private, NoSumDB, Insecure string
+	// NetrcFile is the path of a netrc file containing the credentials used to access private modules.
+	NetrcFile string
+	// NetrcData is netrc data containing the credentials used to access private modules. It is used instead of
+	// NetrcFile, for example if the credentials are stored in an environment variable.
+	NetrcData string
+}
+
+// UsePrivate configures the toolchain to use the provided settings for every command that it runs. The credentials
+// are made available to both the go command, for proxy and module path lookups, and to git, for modules that are
+// fetched directly from their repository. Close must be called when the toolchain is no longer used.
+func (g *Go) UsePrivate(p Private) error {
+	for k, v := range map[string]string{
+		"GOPROXY":    p.Proxy,
+		"GOPRIVATE":  p.Private,
+		"GONOSUMDB":  p.NoSumDB,
+		"GOINSECURE": p.Insecure,
+	} {
+		if v != "" {
+			g.Environ = append(g.Environ, k+"="+v)
+		}
+	}
+
+	data := p.NetrcData
+	netrc := p.NetrcFile
+	if netrc != "" {
+		b, err := os.ReadFile(netrc)
+		if err != nil {
+			return fmt.Errorf("error reading netrc file: %w", err)
+		}
+		data = string(b)
+	} else if data != "" {
+		f, err := os.CreateTemp("", "saddle_netrc_*")
+		if err != nil {
+			return fmt.Errorf("error creating netrc file: %w", err)
+		}
+		g.temp = append(g.temp, f.Name())
+		// CreateTemp already creates the file with permissions that only allow the current user to access it.
+		_, err = f.WriteString(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing netrc file: %w", err)
+		}
+		netrc = f.Name()
+	}
+	if netrc == "" {
+		return nil
+	}
+	machines, err := parseNetrc(data)
+	if err != nil {
+		return err
+	}
+	g.Environ = append(g.Environ, "NETRC="+netrc)
+
+	// Git does not read the netrc file pointed to by NETRC, so the credentials are passed on to git through the
+	// environment by rewriting the URLs of the hosts in the netrc file. This keeps any existing git configuration
+	// passed through the environment intact.
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for _, m := range machines {
+		g.Environ = append(g.Environ,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=url.https://%s@%s/.insteadOf", n, url.UserPassword(m.login, m.password), m.name),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=https://%s/", n, m.name),
+		)
+		n++
+	}
+	g.Environ = append(g.Environ, "GIT_CONFIG_COUNT="+strconv.Itoa(n))
+	return nil
+}
+
+// UseOffline configures the toolchain to never access the network. Only modules that are already present in the
+// module cache can be used.
+func (g *Go) UseOffline() {
+	// Any other flags in GOFLAGS are kept, only the -mod flag is replaced. The current value is read using 'go env', so
+	// that flags set using 'go env -w' are kept as well.
+	current := os.Getenv("GOFLAGS")
+	if env, err := g.Env("GOFLAGS"); err == nil {
+		current = env["GOFLAGS"]
+	}
+	flags := []string{"-mod=mod"}
+	for _, f := range strings.Fields(current) {
+		if name, _, _ := strings.Cut(strings.TrimLeft(f, "-"), "="); name != "mod" {
+			flags = append(flags, f)
+		}
+	}
+	// Modules in the module cache were already verified against the checksum database when they were downloaded, and
+	// the database itself can not be reached without network access.
+	g.Environ = append(g.Environ, "GOFLAGS="+strings.Join(flags, " "), "GOPROXY=off", "GOSUMDB=off")
+}
+
+// Close removes any temporary files created for the toolchain.
+func (g *Go) Close() error {
+	for _, f := range g.temp {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	g.temp = nil
+	return nil
+}
+
+// machine is a single entry in a netrc file.
+type machine struct {
+	name, login, password string
+}
+
+// parseNetrc parses the machines with a login and password from netrc data. Default entries are skipped.
+func parseNetrc(data string) ([]machine, error) {
+	var (
+		machines []machine
+		current  *machine
+	)
+	fields := strings.Fields(data)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine", "login", "password", "account", "macdef":
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("invalid netrc data: missing value for '%s'", fields[i])
+			}
+			i++
+			switch fields[i-1] {
+			case "machine":
+				machines = append(machines, machine{name: fields[i]})
+				current = &machines[len(machines)-1]
+			case "login":
+				if current != nil {
+					current.login = fields[i]
+				}
+			case "password":
+				if current != nil {
+					current.password = fields[i]
+				}
+			}
+		case "default":
+			current = nil
+		}
+	}
+	return filterMachines(machines), nil
+}
+
+// filterMachines returns only the machines that have both a login and a password.
+func filterMachines(machines []machine) []machine {
+	filtered := machines[:0]
+	for _, m := range machines {
+		if m.login != "" && m.password != "" {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
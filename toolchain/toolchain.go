@@ -0,0 +1,151 @@
+package toolchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Go is a Go toolchain installation that is used to build the server.
+type Go struct {
+	// Path is the absolute path to the go executable.
+	Path string
+	// Root is the GOROOT that the toolchain is run with. If empty, the toolchain determines its own root.
+	Root string
+	// Version is the version reported by the toolchain, such as "go1.19.3".
+	Version string
+	// Environ contains extra environment variables, formatted as "key=value", that every command is run with.
+	Environ []string
+
+	// temp holds temporary files that are removed when the toolchain is closed.
+	temp []string
+}
+
+// Find locates the Go toolchain. If path is not empty, the go executable at that path is used. Otherwise, if root is
+// not empty, the go executable in the bin directory of that GOROOT is used. If neither is set, the go executable is
+// looked up on the PATH. The returned error describes how the problem may be solved.
+func Find(path, root string) (*Go, error) {
+	g := &Go{Root: root}
+	switch {
+	case path != "":
+		g.Path = path
+	case root != "":
+		g.Path = filepath.Join(root, "bin", "go")
+	default:
+		p, err := exec.LookPath("go")
+		if err != nil {
+			return nil, errors.New("the go command could not be found on your PATH. Install Go from https://go.dev/dl, " +
+				"or set 'path' or 'root' in the [go] section of saddle.toml to point to an existing installation")
+		}
+		g.Path = p
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(g.Path) == "" {
+		g.Path += ".exe"
+	}
+
+	abs, err := filepath.Abs(g.Path)
+	if err != nil {
+		return nil, err
+	}
+	g.Path = abs
+	if info, err := os.Stat(g.Path); err != nil || info.IsDir() {
+		return nil, fmt.Errorf("no go executable was found at '%s'. Check the [go] section of saddle.toml, or "+
+			"install Go from https://go.dev/dl", g.Path)
+	}
+
+	env, err := g.Env("GOVERSION")
+	if err != nil {
+		return nil, fmt.Errorf("the go executable at '%s' could not be run: %w", g.Path, err)
+	}
+	g.Version = env["GOVERSION"]
+	if g.Version == "" {
+		// The GOVERSION variable was only added in Go 1.16, so anything older does not report it.
+		return nil, fmt.Errorf("the go executable at '%s' is too old. Install a newer version from "+
+			"https://go.dev/dl", g.Path)
+	}
+	return g, nil
+}
+
+// Command returns a command that runs the go executable with the provided arguments.
+func (g *Go) Command(args ...string) *exec.Cmd {
+	return g.CommandContext(context.Background(), args...)
+}
+
+// CommandContext is like Command, but the command is killed if the context is done before the command completes.
+func (g *Go) CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, g.Path, args...)
+	cmd.Env = os.Environ()
+	if g.Root != "" {
+		cmd.Env = append(cmd.Env, "GOROOT="+g.Root)
+	}
+	cmd.Env = append(cmd.Env, g.Environ...)
+	return cmd
+}
+
+// Env returns the values of the provided go environment variables, as reported by 'go env'.
+func (g *Go) Env(vars ...string) (map[string]string, error) {
+	cmd := g.Command(append([]string{"env"}, vars...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	values := strings.Split(strings.TrimRight(string(out), "\r\n"), "\n")
+	env := make(map[string]string, len(vars))
+	for i, v := range vars {
+		if i < len(values) {
+			env[v] = strings.TrimSpace(values[i])
+		}
+	}
+	return env, nil
+}
+
+// Check verifies that the toolchain is at least the provided version, which is formatted like the go directive of a
+// go.mod file, for example "1.19". Development versions of Go are always accepted.
+func (g *Go) Check(minimum string) error {
+	want, ok := parseVersion(minimum)
+	if !ok {
+		return fmt.Errorf("invalid go version '%s', expected a version such as '1.19'", minimum)
+	}
+	have, ok := parseVersion(g.Version)
+	if !ok {
+		// Development builds report a version such as "devel go1.20-abcdef", which can not be compared.
+		return nil
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			if have[i] < want[i] {
+				return fmt.Errorf("go %s is required to build the server, but %s is installed at '%s'. Install a "+
+					"newer version from https://go.dev/dl, or lower 'version' in the [go] section of saddle.toml",
+					minimum, strings.TrimPrefix(g.Version, "go"), g.Path)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// versionRegexp matches Go versions such as "1.19", "go1.19.3" and "go1.21rc2".
+var versionRegexp = regexp.MustCompile(`^(?:go)?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseVersion parses a Go version into its major, minor and patch numbers.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	m := versionRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return parts, false
+	}
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(m[i+1])
+	}
+	return parts, true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/saddlemc/launcher/launcher"
+	"os"
+)
+
+// vendorCommand bundles the server into a directory together with all of its dependencies, so that it can be built
+// using only a Go toolchain, for example on a machine without network access.
+func vendorCommand(logger *zerolog.Logger, args []string) {
+	flags := flag.NewFlagSet("vendor", flag.ExitOnError)
+	bf := buildFlags{
+		out:       new(string),
+		recompile: new(bool),
+		offline: flags.Bool("offline", false,
+			"If set to true, no network access is used, and only modules that are already downloaded are vendored.",
+		),
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: saddle vendor [-offline] <dir>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	plan := resolvePlan(ctx, logger, bf)
+	logger.Info().Msgf("Vendoring server into '%s'...", flags.Arg(0))
+	err := launcher.Vendor(ctx, plan, flags.Arg(0))
+	_ = plan.Close()
+	if err != nil {
+		logger.Fatal().Msgf("Could not vendor server: %v", err)
+	}
+	logger.Info().Msgf("Done! Build the server from this directory using 'saddle build -from-vendor %s'.", flags.Arg(0))
+}